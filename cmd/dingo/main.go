@@ -1,15 +1,29 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
+	"os/signal"
+	"os/user"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
 
 	"github.com/Quok-it/dingo/pkg/dingo"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/term"
 )
 
 /*
@@ -19,30 +33,71 @@ import (
  */
 func main() {
 	var (
-		host       = flag.String("host", "", "SSH host (e.g., user@hostname:port)")
-		ip         = flag.String("ip", "", "Target IP address")
-		port       = flag.String("port", "22", "SSH port")
-		username   = flag.String("user", "user", "SSH username")
-		password   = flag.String("password", "", "SSH password")
-		keyFile    = flag.String("key", "", "SSH private key file (defaults to ~/.ssh/id_rsa)")
-		command    = flag.String("cmd", "", "Command to execute")
-		upload     = flag.String("upload", "", "Upload file (format: local:remote)")
-		download   = flag.String("download", "", "Download file (format: remote:local)")
-		persistent = flag.Bool("persistent", false, "Keep connection alive for continuous operation")
-		interval   = flag.Duration("interval", 30*time.Second, "Interval between operations in persistent mode")
-		script     = flag.String("script", "", "Script file to execute")
-		shell      = flag.Bool("shell", false, "Start interactive shell")
-		footprint  = flag.Bool("footprint", false, "Upload and execute footprint script")
-		hostname   = flag.String("hostname", "", "Hostname to include in footprint (defaults to current hostname)")
-		tail       = flag.String("tail", "", "Tail a file (e.g., /var/log/syslog)")
-		follow     = flag.Bool("follow", false, "Follow file changes (like tail -f)")
-		restore    = flag.Bool("restore", false, "Restore a previously started session, needs ip-address")
-		no_restore = flag.Bool("no_restore", false, "Don't enable session restoration, automatically set to true when screen is not installed")
-		lines      = flag.Int("lines", 10, "Number of lines to show initially when tailing")
-		stream     = flag.Bool("stream", false, "Stream command output in real-time with separate stdout/stderr")
+		host        = flag.String("host", "", "SSH host (e.g., user@hostname:port)")
+		ip          = flag.String("ip", "", "Target IP address")
+		port        = flag.String("port", "22", "SSH port")
+		username    = flag.String("user", "user", "SSH username")
+		password    = flag.String("password", "", "SSH password")
+		keyFile     = flag.String("key", "", "SSH private key file (defaults to ~/.ssh/id_rsa)")
+		command     = flag.String("cmd", "", "Command to execute")
+		upload      = flag.String("upload", "", "Upload a file or directory (format: local:remote)")
+		download    = flag.String("download", "", "Download a file or directory (format: remote:local)")
+		progress    = flag.Bool("progress", true, "Report -upload/-download progress on stderr: a progress bar on a terminal, JSON lines otherwise")
+		resume      = flag.Bool("resume", false, "Continue interrupted -upload/-download transfers from their .dingo-partial files, other existing files are kept only if their SHA-256 matches")
+		resumeHash  = flag.Bool("resume-verify", false, "With -resume, also compare SHA-256 hashes of .dingo-partial files before continuing")
+		preserve    = flag.Bool("preserve", false, "Keep mode, access and modification times of -upload/-download files, like scp -p")
+		preserveID  = flag.Bool("preserve-owner", false, "With -upload/-download, also keep the numeric uid and gid (usually needs root at the destination)")
+		atomicWrite = flag.Bool("atomic", false, "Write -upload/-download files to a temporary name and rename them into place once complete")
+		verify      = flag.Bool("verify", false, "Compare checksums of source and destination after -upload/-download, fails on a mismatch")
+		checksum    = flag.String("checksum", string(dingo.ChecksumSHA256), "Hash used by -verify: sha256, sha512, sha1 or md5")
+		symlinks    = flag.String("symlinks", string(dingo.SymlinkFollow), "How directory transfers handle symbolic links: follow, preserve or skip")
+		persistent  = flag.Bool("persistent", false, "Keep connection alive for continuous operation")
+		interval    = flag.Duration("interval", 30*time.Second, "Interval between operations in persistent mode")
+		script      = flag.String("script", "", "Script file to execute")
+		shell       = flag.Bool("shell", false, "Start interactive shell")
+		footprint   = flag.Bool("footprint", false, "Upload and execute footprint script")
+		hostname    = flag.String("hostname", "", "Hostname to include in footprint (defaults to current hostname)")
+		tail        = flag.String("tail", "", "Tail a file (e.g., /var/log/syslog)")
+		follow      = flag.Bool("follow", false, "Follow file changes (like tail -f)")
+		restore     = flag.Bool("restore", false, "Restore a previously started session, needs ip-address")
+		no_restore  = flag.Bool("no_restore", false, "Don't enable session restoration, automatically set to true when neither tmux nor screen is installed")
+		session     = flag.String("session", defaultSessionName(), "Name of the persistent tmux/screen session used by -shell and -restore")
+		listSess    = flag.Bool("list-sessions", false, "List the persistent tmux/screen sessions on the remote host")
+		lines       = flag.Int("lines", 10, "Number of lines to show initially when tailing")
+		stream      = flag.Bool("stream", false, "Stream command output in real-time with separate stdout/stderr")
+		grace       = flag.Duration("grace", 5*time.Second, "Time the remote process gets to exit after a forwarded signal before the session is closed")
+		record      = flag.String("record", "", "Record the interactive session to an asciicast v2 file (e.g., session.cast)")
+		recordIn    = flag.Bool("record-input", false, "Also record typed input with -record (input after password prompts is never recorded)")
+		share       = flag.String("share", "", "Mirror the interactive shell to local observers on unix:/path or tcp:127.0.0.1:port (connect with socat -,raw,echo=0 UNIX-CONNECT:/path)")
+		shareInput  = flag.Bool("share-input", false, "Let -share observers type into the shell, they are read-only by default")
+		agentFwd    = flag.Bool("A", false, "Forward the local ssh-agent (SSH_AUTH_SOCK) to -cmd, -script and -shell sessions, off by default and only for trusted hosts")
+		escape      = flag.String("escape", "~", "Escape character for interactive shells, \"none\" disables escapes (type ~? in a shell for help)")
+		detach      = flag.Bool("detach", false, "Run -cmd as a detached background job that survives the connection and print its job ID")
+		jobs        = flag.Bool("jobs", false, "List the detached jobs on the remote host")
+		jobID       = flag.String("job", "", "Show the status of a detached job by ID")
+		jobLogs     = flag.Bool("job-logs", false, "With -job, print the last -lines lines of the job's stdout and stderr")
+		jobWait     = flag.Bool("job-wait", false, "With -job, wait for the job to finish and exit with its exit code")
+		jobCancel   = flag.Bool("job-cancel", false, "With -job, terminate the job")
+		scriptVars  = make(templateVars)
+		localFwds   forwardSpecs
+		remoteFwds  forwardSpecs
+		socksFwds   forwardSpecs
+		includes    patternList
+		excludes    patternList
 	)
+	flag.Var(scriptVars, "var", "Template variable for -script (format: key=value, repeatable)")
+	flag.Var(&includes, "include", "With a directory -upload/-download, only transfer files matching this glob (e.g., *.safetensors, repeatable)")
+	flag.Var(&excludes, "exclude", "With a directory -upload/-download, leave out files and directories matching this glob (e.g., .git, repeatable)")
+	flag.Var(&localFwds, "L", "Forward a local port or socket through the remote host (format: [bind_address:]port:host:hostport, either side may be a socket path, repeatable)")
+	flag.Var(&remoteFwds, "R", "Forward a port or socket on the remote host back to this machine (format: [bind_address:]port:host:hostport, either side may be a socket path, repeatable)")
+	flag.Var(&socksFwds, "D", "Run a SOCKS5 proxy whose connections are made from the remote host (format: [bind_address:]port, repeatable)")
 	flag.Parse()
 
+	if *resumeHash && !*resume {
+		fmt.Fprintf(os.Stderr, "Error: -resume-verify requires -resume\n")
+		os.Exit(1)
+	}
+
 	// Handle new IP/port style or traditional host style
 	var hostAddr string
 	if *ip != "" {
@@ -80,33 +135,72 @@ func main() {
 	}
 	defer client.Close()
 
+	if *agentFwd {
+		if err := forwardLocalAgent(client); err != nil {
+			log.Fatalf("Failed to forward agent: %v", err)
+		}
+	}
+
+	interactive := interactiveOptions{
+		client:      client,
+		record:      *record,
+		recordInput: *recordIn,
+		share:       *share,
+		shareInput:  *shareInput,
+		escape:      *escape,
+		forwards:    newForwardings(client),
+	}
+	defer interactive.forwards.closeAll()
+
+	for _, fw := range []struct {
+		kind  string
+		specs forwardSpecs
+	}{{"L", localFwds}, {"R", remoteFwds}, {"D", socksFwds}} {
+		for _, spec := range fw.specs {
+			if err := interactive.forwards.open(fw.kind, spec); err != nil {
+				log.Fatalf("Failed to forward -%s %s: %v", fw.kind, spec, err)
+			}
+		}
+	}
+
+	if *listSess {
+		if err := handleListSessions(client); err != nil {
+			log.Fatalf("Failed to list sessions: %v", err)
+		}
+		return
+	}
+
 	if (*restore || !*no_restore) && *ip == "" {
 		fmt.Fprintf(os.Stderr, "Warning: Session restoration functionality requires the -ip flag. Disabling.\n")
 		*no_restore = true
 	}
 
-	var sessionName string
-	useScreen := !*no_restore
-
-	if useScreen {
-		// Check if screen is available on the remote host.
-		if !isScreenInstalled(client) {
-			fmt.Println("Warning: 'screen' is not installed on the remote host. Disabling session restore")
-			useScreen = false
-		} else {
-			sessionName = "dingo"
+	// Shells run inside a persistent tmux/screen session unless disabled or unavailable
+	sessionSet := false
+	flag.Visit(func(f *flag.Flag) {
+		sessionSet = sessionSet || f.Name == "session"
+	})
+	var persistentSession dingo.PersistentSession
+	if !*no_restore {
+		persistentSession = client.PersistentSession(*session)
+		if _, err := persistentSession.Multiplexer(); err != nil {
+			fmt.Printf("Warning: %v. Disabling session restore\n", err)
+			persistentSession = nil
+		} else if !sessionSet {
+			persistentSession = defaultPersistentSession(client, persistentSession)
+			*session = persistentSession.Name()
 		}
 	}
 
 	if *restore {
-		if !useScreen {
-			log.Fatalf("Cannot restore session: 'screen' is unavailable or session features were disabled.")
+		if persistentSession == nil {
+			log.Fatalf("Cannot restore session: no terminal multiplexer is available or session features were disabled.")
 		}
-		err = handleRestore(client, sessionName)
+		err = handleRestore(persistentSession, interactive)
 		if err != nil {
-			log.Fatalf("Failed to restore session '%s': %v", sessionName, err)
+			log.Fatalf("Failed to restore session '%s': %v", *session, err)
 		}
-		fmt.Printf("Detached from session '%s'.\n", sessionName)
+		fmt.Printf("Detached from session '%s'.\n", *session)
 		return // Exit after the restore attempt.
 	}
 
@@ -119,11 +213,29 @@ func main() {
 		return
 	}
 
+	// Handle detached jobs
+	if *detach || *jobs || *jobID != "" {
+		switch {
+		case *detach:
+			err = handleDetach(client, *command)
+		case *jobs:
+			err = handleListJobs(client)
+		default:
+			err = handleJob(client, *jobID, *jobLogs, *jobWait, *jobCancel, *lines)
+		}
+		if err != nil {
+			log.Printf("Job operation failed: %v", err)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+
 	// Handle tail mode
 	if *tail != "" {
-		err = handleTail(client, *tail, *follow, *lines)
+		err = handleTail(client, *tail, *follow, *lines, *grace)
 		if err != nil {
-			log.Fatalf("Tail operation failed: %v", err)
+			log.Printf("Tail operation failed: %v", err)
+			os.Exit(exitCode(err))
 		}
 		return
 	}
@@ -132,49 +244,86 @@ func main() {
 	if *persistent {
 		err = runPersistentMode(client, *command, *interval)
 	} else {
-		err = runSingleMode(client, *command, *upload, *download, *script, *shell, *stream, useScreen, sessionName)
+		transfer := []dingo.TransferOption{
+			dingo.WithSymlinks(dingo.SymlinkPolicy(*symlinks)),
+			dingo.WithInclude(includes...),
+			dingo.WithExclude(excludes...),
+			dingo.WithResume(*resume),
+			dingo.WithVerifyResume(*resumeHash),
+			dingo.WithPreserve(*preserve),
+			dingo.WithPreserveOwner(*preserveID),
+			dingo.WithAtomic(*atomicWrite),
+			dingo.WithVerify(*verify),
+			dingo.WithChecksum(dingo.ChecksumAlgorithm(*checksum)),
+		}
+		if *progress {
+			transfer = append(transfer, progressOptions(os.Stderr)...)
+		}
+		err = runSingleMode(client, *command, *upload, *download, *script, flag.Args(), scriptVars, *shell, *stream, *grace, persistentSession, interactive, transfer)
 	}
 
 	if err != nil {
-		log.Fatalf("Operation failed: %v", err)
+		log.Printf("Operation failed: %v", err)
+		os.Exit(exitCode(err))
 	}
 }
 
 /*
-* Handles footprint operation - uploads and executes a script that leaves a trace file
-* Inputs: client (dingo.SSHClient) - established SSH connection, hostname (string) - hostname to include in footprint
-* Outputs: error if footprint operation fails, nil on success
+* Determines the process exit code for an operation error, propagating the remote exit status when known
+* Inputs: err (error) - error returned by the operation
+* Outputs: int containing the exit code (remote status, 128+signal for interrupted commands, 1 otherwise)
  */
-func handleFootprint(client dingo.SSHClient, hostname string) error {
-	// Get hostname if not provided
-	if hostname == "" {
-		var err error
-		hostname, err = os.Hostname()
-		if err != nil {
-			hostname = "unknown"
-		}
+func exitCode(err error) int {
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus()
+	}
+	var signalErr *dingo.SignalError
+	if errors.As(err, &signalErr) {
+		return signalErr.ExitStatus()
 	}
+	var jobErr *jobExitError
+	if errors.As(err, &jobErr) {
+		return jobErr.status.ExitCode
+	}
+	return 1
+}
+
+/*
+* Runs a command while forwarding SIGINT, SIGTERM and SIGHUP received by dingo to the remote process
+* Inputs: cmd (dingo.CommandExecutor) - command to run, grace (time.Duration) - time the remote process gets to exit before the session is closed
+* Outputs: error if the command fails or is interrupted, nil on success
+ */
+func runWithSignals(cmd dingo.CommandExecutor, grace time.Duration) error {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	return cmd.ForwardSignals(signals, grace).Run()
+}
 
-	// Create footprint script content
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	scriptContent := fmt.Sprintf(`#!/bin/bash
+// footprintScript is the template for the footprint script, variables are shell-quoted when rendered
+const footprintScript = `#!/bin/bash
 
 # Dingo SSH Footprint Script
+SOURCE_HOSTNAME={{.Hostname}}
+EXECUTION_TIME={{.Timestamp}}
+
 echo "=== Dingo SSH Footprint ==="
-echo "Executed by: %s"
-echo "Timestamp: %s"
+echo "Executed by: $SOURCE_HOSTNAME"
+echo "Timestamp: $EXECUTION_TIME"
 echo "Target system: $(hostname)"
 echo "Current user: $(whoami)"
 echo "Working directory: $(pwd)"
 echo "System info: $(uname -a)"
 
 # Create footprint file
-FOOTPRINT_FILE="/tmp/dingo_footprint_$(date +%%s).txt"
+FOOTPRINT_FILE="/tmp/dingo_footprint_$(date +%s).txt"
 cat > "$FOOTPRINT_FILE" << EOF
 Dingo SSH Client Footprint
 ==========================
-Source hostname: %s
-Execution time: %s
+Source hostname: $SOURCE_HOSTNAME
+Execution time: $EXECUTION_TIME
 Target hostname: $(hostname)
 Target user: $(whoami)
 Target system: $(uname -a)
@@ -192,7 +341,31 @@ echo "File permissions:"
 ls -la "$FOOTPRINT_FILE"
 echo ""
 echo "✓ Footprint operation completed successfully!"
-`, hostname, timestamp, hostname, timestamp)
+`
+
+/*
+* Handles footprint operation - uploads and executes a script that leaves a trace file
+* Inputs: client (dingo.SSHClient) - established SSH connection, hostname (string) - hostname to include in footprint
+* Outputs: error if footprint operation fails, nil on success
+ */
+func handleFootprint(client dingo.SSHClient, hostname string) error {
+	// Get hostname if not provided
+	if hostname == "" {
+		var err error
+		hostname, err = os.Hostname()
+		if err != nil {
+			hostname = "unknown"
+		}
+	}
+
+	// Render footprint script content
+	scriptContent, err := dingo.RenderScriptTemplate(footprintScript, map[string]any{
+		"Hostname":  hostname,
+		"Timestamp": time.Now().Format("2006-01-02 15:04:05"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render footprint script: %v", err)
+	}
 
 	fmt.Printf("Creating footprint script for hostname: %s\n", hostname)
 
@@ -201,29 +374,14 @@ echo "✓ Footprint operation completed successfully!"
 	fs := client.FileSystem()
 	defer fs.Close()
 
-	// Upload the script
-	err := fs.WriteFile(tmpScript, []byte(scriptContent), 0644)
+	// Upload the script, a newly created file gets the executable permissions
+	err = fs.WriteFile(tmpScript, []byte(scriptContent), 0755)
 	if err != nil {
 		return fmt.Errorf("failed to upload footprint script: %v", err)
 	}
 
 	fmt.Printf("✓ Footprint script uploaded to: %s\n", tmpScript)
 
-	// Explicitly set executable permissions
-	err = fs.Chmod(tmpScript, 0755)
-	if err != nil {
-		return fmt.Errorf("failed to set executable permissions: %v", err)
-	}
-
-	fmt.Println("✓ Executable permissions set")
-
-	// Verify the script exists and has correct permissions
-	fileInfo, err := fs.Stat(tmpScript)
-	if err != nil {
-		return fmt.Errorf("failed to verify script file: %v", err)
-	}
-	fmt.Printf("✓ Script verified: %s (permissions: %s)\n", fileInfo.Name(), fileInfo.Mode())
-
 	// Execute the script using bash explicitly
 	fmt.Println("Executing footprint script...")
 	cmd := client.Command(fmt.Sprintf("bash %s", tmpScript))
@@ -250,6 +408,23 @@ echo "✓ Footprint operation completed successfully!"
 	return nil
 }
 
+/*
+* Forwards the ssh-agent listening on SSH_AUTH_SOCK to the sessions of the connection, like ssh -A
+* Inputs: client (dingo.SSHClient) - established SSH connection
+* Outputs: error if no agent is running or forwarding cannot be enabled
+ */
+func forwardLocalAgent(client dingo.SSHClient) error {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return errors.New("SSH_AUTH_SOCK is not set, start ssh-agent and add your keys with ssh-add")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return fmt.Errorf("cannot reach ssh-agent: %w", err)
+	}
+	return client.EnableAgentForwarding(agent.NewClient(conn))
+}
+
 /*
 * Establishes SSH connection using provided credentials (password or key-based authentication)
 * Inputs: host (string) - SSH server address, password (string) - password or empty, keyFile (string) - private key path or empty
@@ -338,45 +513,50 @@ func runPersistentMode(client dingo.SSHClient, command string, interval time.Dur
 
 /*
 * Runs the application in single-operation mode, executing one task and exiting
-* Inputs: client (dingo.SSHClient) - established SSH connection, command (string) - command to execute, upload (string) - upload spec, download (string) - download spec, script (string) - script file path, shell (bool) - whether to start interactive shell, stream (bool) - whether to stream output
+* Inputs: client (dingo.SSHClient) - established SSH connection, command (string) - command to execute, upload (string) - upload spec, download (string) - download spec, script (string) - script file path, scriptArgs ([]string) - arguments passed to the script, scriptVars (templateVars) - template variables for the script, shell (bool) - whether to start interactive shell, stream (bool) - whether to stream output, grace (time.Duration) - grace period for forwarded signals, transfer ([]dingo.TransferOption) - options for -upload and -download
 * Outputs: error if any operation fails, nil on successful completion
  */
-func runSingleMode(client dingo.SSHClient, command, upload, download, script string, shell bool, stream bool, useScreen bool, sessionName string) error {
+func runSingleMode(client dingo.SSHClient, command, upload, download, script string, scriptArgs []string, scriptVars templateVars, shell bool, stream bool, grace time.Duration, persistentSession dingo.PersistentSession, interactive interactiveOptions, transfer []dingo.TransferOption) error {
 	// Handle file operations
 	if upload != "" {
-		return handleUpload(client, upload)
+		return handleUpload(client, upload, transfer)
 	}
 	if download != "" {
-		return handleDownload(client, download)
+		return handleDownload(client, download, transfer)
 	}
 
 	// Handle script execution
 	if script != "" {
-		return handleScript(client, script)
+		return handleScript(client, script, scriptArgs, scriptVars, grace)
 	}
 
 	// Handle interactive shell
 	if shell {
-		return handleShell(client, useScreen, sessionName)
+		return handleShell(client, persistentSession, interactive)
 	}
 
 	// Handle command execution
 	if command != "" {
 		if stream {
-			return handleStreamCommand(client, command)
+			return handleStreamCommand(client, command, grace)
 		}
 		return handleCommand(client, command)
 	}
 
+	// Like ssh -N, forwards alone keep the connection open
+	if interactive.forwards.count() > 0 {
+		return waitForwarding(client)
+	}
+
 	return fmt.Errorf("no operation specified")
 }
 
 /*
-* Handles file upload operation from local to remote server
-* Inputs: client (dingo.SSHClient) - established SSH connection, upload (string) - upload specification in format "local:remote"
+* Handles file upload operation from local to remote server, a local directory is uploaded recursively
+* Inputs: client (dingo.SSHClient) - established SSH connection, upload (string) - upload specification in format "local:remote", transfer ([]dingo.TransferOption) - transfer options
 * Outputs: error if upload fails, nil on successful upload
  */
-func handleUpload(client dingo.SSHClient, upload string) error {
+func handleUpload(client dingo.SSHClient, upload string, transfer []dingo.TransferOption) error {
 	parts := strings.Split(upload, ":")
 	if len(parts) != 2 {
 		return fmt.Errorf("invalid upload format, use: local:remote")
@@ -386,15 +566,18 @@ func handleUpload(client dingo.SSHClient, upload string) error {
 	fs := client.FileSystem()
 	defer fs.Close()
 
-	return fs.Upload(parts[0], parts[1])
+	if info, err := os.Stat(parts[0]); err == nil && info.IsDir() {
+		return fs.UploadDir(parts[0], parts[1], transfer...)
+	}
+	return fs.Upload(parts[0], parts[1], transfer...)
 }
 
 /*
-* Handles file download operation from remote server to local filesystem
-* Inputs: client (dingo.SSHClient) - established SSH connection, download (string) - download specification in format "remote:local"
+* Handles file download operation from remote server to local filesystem, a remote directory is downloaded recursively
+* Inputs: client (dingo.SSHClient) - established SSH connection, download (string) - download specification in format "remote:local", transfer ([]dingo.TransferOption) - transfer options
 * Outputs: error if download fails, nil on successful download
  */
-func handleDownload(client dingo.SSHClient, download string) error {
+func handleDownload(client dingo.SSHClient, download string, transfer []dingo.TransferOption) error {
 	parts := strings.Split(download, ":")
 	if len(parts) != 2 {
 		return fmt.Errorf("invalid download format, use: remote:local")
@@ -404,32 +587,705 @@ func handleDownload(client dingo.SSHClient, download string) error {
 	fs := client.FileSystem()
 	defer fs.Close()
 
-	return fs.Download(parts[0], parts[1])
+	if info, err := fs.Stat(parts[0]); err == nil && info.IsDir() {
+		return fs.DownloadDir(parts[0], parts[1], transfer...)
+	}
+	return fs.Download(parts[0], parts[1], transfer...)
+}
+
+/*
+* Builds the transfer options that report progress on a terminal or as JSON lines
+* Inputs: out (*os.File) - stream the progress is written to
+* Outputs: []dingo.TransferOption containing the progress callback and its interval
+ */
+func progressOptions(out *os.File) []dingo.TransferOption {
+	if term.IsTerminal(int(out.Fd())) {
+		bar := &progressBar{out: out, fd: int(out.Fd())}
+		return []dingo.TransferOption{dingo.WithProgress(bar.render), dingo.WithProgressInterval(200 * time.Millisecond)}
+	}
+
+	encoder := json.NewEncoder(out)
+	return []dingo.TransferOption{dingo.WithProgress(func(p dingo.TransferProgress) {
+		encoder.Encode(progressLine{
+			File:       p.File,
+			FilesDone:  p.FilesDone,
+			FilesTotal: p.FilesTotal,
+			BytesDone:  p.BytesDone,
+			BytesTotal: p.BytesTotal,
+			Rate:       int64(p.Rate),
+			Elapsed:    p.Elapsed.Seconds(),
+		})
+	}), dingo.WithProgressInterval(5 * time.Second)}
+}
+
+// progressLine is the JSON form of the transfer progress printed when stderr is not a terminal
+type progressLine struct {
+	File       string  `json:"file"`
+	FilesDone  int     `json:"files_done"`
+	FilesTotal int     `json:"files_total"`
+	BytesDone  int64   `json:"bytes_done"`
+	BytesTotal int64   `json:"bytes_total"`
+	Rate       int64   `json:"bytes_per_second"`
+	Elapsed    float64 `json:"elapsed_seconds"`
+}
+
+// progressBar redraws a single terminal line with the transfer progress
+type progressBar struct {
+	out *os.File
+	fd  int
 }
 
 /*
-* Handles script file execution on the remote server
-* Inputs: client (dingo.SSHClient) - established SSH connection, script (string) - path to local script file
+* Redraws the progress line, the line is finished once every file has been copied
+* Inputs: p (dingo.TransferProgress) - current progress
+* Outputs: none
+ */
+func (b *progressBar) render(p dingo.TransferProgress) {
+	width := 80
+	if w, _, err := term.GetSize(b.fd); err == nil && w > 0 {
+		width = w
+	}
+
+	percent := 100.0
+	if p.BytesTotal > 0 {
+		percent = float64(p.BytesDone) * 100 / float64(p.BytesTotal)
+	}
+	eta := "--:--"
+	if p.Rate > 0 && p.BytesTotal >= p.BytesDone {
+		remaining := time.Duration(float64(p.BytesTotal-p.BytesDone) / p.Rate * float64(time.Second))
+		eta = fmt.Sprintf("%02d:%02d", int(remaining.Minutes()), int(remaining.Seconds())%60)
+	}
+	stats := fmt.Sprintf(" %5.1f%% %s/%s %s/s ETA %s %d/%d ", percent, formatBytes(p.BytesDone), formatBytes(p.BytesTotal), formatBytes(int64(p.Rate)), eta, p.FilesDone, p.FilesTotal)
+
+	// The bar gets a third of the line, the file name whatever is left
+	barWidth := width / 3
+	filled := int(percent / 100 * float64(barWidth))
+	if filled > barWidth {
+		filled = barWidth
+	}
+	name := filepath.Base(p.File)
+	if room := width - barWidth - len(stats) - 3; room < len(name) {
+		name = ""
+		if room > 3 {
+			name = filepath.Base(p.File)[:room-3] + "..."
+		}
+	}
+	fmt.Fprintf(b.out, "\r[%s%s]%s%s\x1b[K", strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled), stats, name)
+	if p.FilesDone == p.FilesTotal {
+		fmt.Fprintln(b.out)
+	}
+}
+
+/*
+* Formats a byte count with binary units
+* Inputs: n (int64) - number of bytes
+* Outputs: string such as "512 B" or "1.5 GiB"
+ */
+func formatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	unit := -1
+	for value >= 1024 && unit < 4 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[unit])
+}
+
+/*
+* Handles script file execution on the remote server, honouring the script's shebang
+* Scripts ending in .tmpl or given -var values are rendered as templates first
+* Inputs: client (dingo.SSHClient) - established SSH connection, script (string) - path to local script file, args ([]string) - positional arguments for the script, vars (templateVars) - template variables, grace (time.Duration) - grace period for forwarded signals
 * Outputs: error if script execution fails, nil on successful execution
  */
-func handleScript(client dingo.SSHClient, script string) error {
+func handleScript(client dingo.SSHClient, script string, args []string, vars templateVars, grace time.Duration) error {
 	fmt.Printf("Executing script: %s\n", script)
-	return client.ScriptFile(script).Run()
+	opts := []dingo.ScriptOption{dingo.WithShebang(true), dingo.WithArgs(args...)}
+
+	if strings.HasSuffix(script, ".tmpl") || len(vars) > 0 {
+		dir, name := filepath.Split(script)
+		if dir == "" {
+			dir = "."
+		}
+		return runWithSignals(client.ScriptTemplateFS(os.DirFS(dir), name, vars.values(), opts...), grace)
+	}
+	return runWithSignals(client.ScriptFile(script, opts...), grace)
+}
+
+// templateVars collects repeated -var key=value flags
+type templateVars map[string]string
+
+/*
+* Returns the collected variables formatted for flag usage output
+* Inputs: none
+* Outputs: string containing comma-separated key=value pairs
+ */
+func (v templateVars) String() string {
+	pairs := make([]string, 0, len(v))
+	for key, value := range v {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+/*
+* Parses a single key=value flag value and stores it
+* Inputs: value (string) - flag value in format key=value
+* Outputs: error if the value is not in key=value format
+ */
+func (v templateVars) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("invalid variable %q, use: key=value", value)
+	}
+	v[key] = val
+	return nil
+}
+
+/*
+* Converts the collected variables into template data
+* Inputs: none
+* Outputs: map[string]any containing the variables
+ */
+func (v templateVars) values() map[string]any {
+	vars := make(map[string]any, len(v))
+	for key, value := range v {
+		vars[key] = value
+	}
+	return vars
 }
 
 /*
 * Handles interactive shell session with the remote server
-* Inputs: client (dingo.SSHClient) - established SSH connection
+* Inputs: client (dingo.SSHClient) - established SSH connection, session (dingo.PersistentSession) - session to create or attach, nil for a plain shell, opts (interactiveOptions) - recording and escape settings
 * Outputs: error if shell startup fails, nil on successful shell session completion
  */
-func handleShell(client dingo.SSHClient, useScreen bool, sessionName string) error {
+func handleShell(client dingo.SSHClient, session dingo.PersistentSession, opts interactiveOptions) error {
 	fmt.Println("Starting interactive shell...")
-	shell := client.InteractiveShell(nil)
-	command := ""
-	if useScreen {
-		command = fmt.Sprintf("screen -mS %s", sessionName)
+	config := localTerminalConfig()
+	if session == nil {
+		return runInteractive(client.InteractiveShell(config), config, opts)
+	}
+
+	fmt.Printf("Using persistent session '%s'.\n", session.Name())
+	return runInteractive(session.Attach(config), config, opts)
+}
+
+// interactiveOptions holds the settings shared by interactive shells started from the CLI
+type interactiveOptions struct {
+	client      dingo.SSHClient
+	record      string // asciicast file to record to, "" to disable
+	recordInput bool   // also record typed input
+	share       string // unix:/path or tcp:host:port to share the shell on, "" to disable
+	shareInput  bool   // let observers type into the shell
+	escape      string // escape character, "none" to disable escapes
+	forwards    *forwardings
+}
+
+/*
+* Runs an interactive shell attached to the local terminal with escape sequences, optionally recording and sharing the session
+* Inputs: shell (dingo.Shell) - shell to run, config (*dingo.TerminalConfig) - terminal configuration of the shell, nil if stdin is not a terminal, opts (interactiveOptions) - recording, sharing and escape settings
+* Outputs: error if the shell or the recording fails, nil if the session ended or was disconnected with "~."
+ */
+func runInteractive(shell dingo.Shell, config *dingo.TerminalConfig, opts interactiveOptions) error {
+	if opts.record != "" {
+		file, err := os.Create(opts.record)
+		if err != nil {
+			return fmt.Errorf("failed to create recording file: %v", err)
+		}
+		defer file.Close()
+
+		fmt.Printf("Recording session to %s\n", opts.record)
+		shell = dingo.RecordShell(shell, file, dingo.WithRecordTerminal(config), dingo.WithRecordInput(opts.recordInput))
+	}
+
+	if opts.share != "" {
+		listener, err := listenShare(opts.share)
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+
+		access := "read-only"
+		if opts.shareInput {
+			access = "with input"
+		}
+		fmt.Printf("Sharing session on %s (%s)\n", opts.share, access)
+		shell = dingo.ShareShell(shell, listener, dingo.WithObserverInput(opts.shareInput))
+	}
+
+	// Like OpenSSH, escapes are only available when typing on a terminal
+	var escapes *escapeSession
+	if config != nil && opts.escape != "none" {
+		if len(opts.escape) != 1 {
+			return fmt.Errorf("invalid escape character %q", opts.escape)
+		}
+		escapes = &escapeSession{client: opts.client, forwards: opts.forwards, escape: opts.escape[0]}
+		escapes.reader = dingo.NewEscapeReader(os.Stdin, opts.escape[0], escapes.handle)
+		shell.SetStdio(escapes.reader, os.Stdout, os.Stderr)
+	}
+
+	err := dingo.RunInLocalTerminal(shell, "")
+	if escapes != nil && escapes.disconnected.Load() {
+		fmt.Println("Connection closed.")
+		return nil
+	}
+	return err
+}
+
+/*
+* Opens the local listener observers of a shared session connect to
+* Unix sockets are only accessible by the current user, TCP listeners must use a loopback address
+* Inputs: spec (string) - unix:/path, tcp:host:port, or a plain socket path
+* Outputs: net.Listener accepting observers, error if the address is invalid or cannot be listened on
+ */
+func listenShare(spec string) (net.Listener, error) {
+	network, address, ok := strings.Cut(spec, ":")
+	if !ok || (network != "unix" && network != "tcp") {
+		network, address = "unix", spec
+	}
+
+	if network == "tcp" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid share address %q: %v", spec, err)
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return nil, fmt.Errorf("refusing to share the session on non-loopback address %q", address)
+		}
+		return net.Listen("tcp", address)
+	}
+
+	// Remove a socket left behind by an earlier session, but never a regular file
+	if info, err := os.Lstat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(address)
+	}
+	return dingo.ListenUnix(address)
+}
+
+// escapeSession handles "~" escape sequences typed into an interactive shell
+type escapeSession struct {
+	client       dingo.SSHClient
+	forwards     *forwardings
+	escape       byte
+	reader       *dingo.EscapeReader
+	disconnected atomic.Bool // Set by the goroutine reading stdin, read once the shell has ended
+}
+
+/*
+* Runs the action for an escape sequence, the terminal is in raw mode so output lines end in \r\n
+* Inputs: command (byte) - character typed after the escape character
+* Outputs: bool - true if the sequence was handled, false to send it to the remote side
+ */
+func (es *escapeSession) handle(command byte) bool {
+	switch command {
+	case '.':
+		es.disconnected.Store(true)
+		fmt.Print("\r\n")
+		es.client.Close()
+	case '?':
+		e := string(es.escape)
+		fmt.Print("\r\nSupported escape sequences:\r\n" +
+			" " + e + ".   - terminate connection\r\n" +
+			" " + e + "C   - open a command line\r\n" +
+			" " + e + "#   - list forwarded connections\r\n" +
+			" " + e + "?   - this message\r\n" +
+			" " + e + e + "   - send the escape character by typing it twice\r\n" +
+			"(Note that escapes are only recognized immediately after newline.)\r\n")
+	case '#':
+		fmt.Print("\r\n" + strings.ReplaceAll(es.forwards.describe(), "\n", "\r\n"))
+	case 'C':
+		if len(es.forwards.kinds()) == 0 {
+			fmt.Print("\r\nNo forwarding types are available.\r\n")
+			return true
+		}
+		fmt.Print("\r\ndingo> ")
+		line, err := es.reader.ReadLine(os.Stdout)
+		if err != nil {
+			return true
+		}
+		if err := es.forwards.command(line); err != nil {
+			fmt.Printf("%v\r\n", err)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// forwarding is a port forward opened by the CLI
+type forwarding struct {
+	kind   string // Forwarding flag without the dash, e.g. "L"
+	spec   string // Specification as given on the command line
+	closer io.Closer
+}
+
+// forwardings tracks the forwards opened by the CLI so "~#" can list them and "~C" can add or cancel them
+type forwardings struct {
+	mu      sync.Mutex
+	openers map[string]func(spec string) (io.Closer, error) // Supported forwarding kinds
+	active  []forwarding
+}
+
+/*
+* Creates an empty forwarding registry
+* Inputs: client (dingo.SSHClient) - connection the forwards are opened on
+* Outputs: *forwardings with the supported forwarding kinds registered
+ */
+func newForwardings(client dingo.SSHClient) *forwardings {
+	reportError := dingo.WithForwardErrorHandler(func(err error) {
+		log.Printf("Forwarding failed: %v", err)
+	})
+
+	return &forwardings{
+		openers: map[string]func(spec string) (io.Closer, error){
+			"L": func(spec string) (io.Closer, error) {
+				listen, target, err := parseForwardSpec(spec)
+				if err != nil {
+					return nil, err
+				}
+				forward, err := client.ForwardLocal(listen, target, reportError)
+				if err != nil {
+					return nil, err
+				}
+				fmt.Printf("Forwarding %s to %s on the remote host\n", forward.Addr(), target)
+				return forward, nil
+			},
+			"R": func(spec string) (io.Closer, error) {
+				listen, target, err := parseForwardSpec(spec)
+				if err != nil {
+					return nil, err
+				}
+				forward, err := client.ForwardRemote(listen, target, reportError)
+				if err != nil {
+					return nil, err
+				}
+				fmt.Printf("Forwarding %s on the remote host to %s\n", forward.Addr(), target)
+				return forward, nil
+			},
+			"D": func(spec string) (io.Closer, error) {
+				listen, err := parseDynamicSpec(spec)
+				if err != nil {
+					return nil, err
+				}
+				forward, err := client.ForwardDynamic(listen, reportError)
+				if err != nil {
+					return nil, err
+				}
+				fmt.Printf("SOCKS5 proxy listening on %s\n", forward.Addr())
+				return forward, nil
+			},
+		},
+	}
+}
+
+/*
+* Opens a forward and records it
+* Inputs: kind (string) - forwarding kind, e.g. "L", spec (string) - forwarding specification
+* Outputs: error if the kind is unsupported or the forward cannot be opened
+ */
+func (f *forwardings) open(kind, spec string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	opener, ok := f.openers[kind]
+	if !ok {
+		return fmt.Errorf("-%s forwarding is not supported", kind)
+	}
+	closer, err := opener(spec)
+	if err != nil {
+		return err
+	}
+	f.active = append(f.active, forwarding{kind: kind, spec: spec, closer: closer})
+	return nil
+}
+
+/*
+* Closes and forgets a forward
+* Inputs: kind (string) - forwarding kind, spec (string) - specification the forward was opened with
+* Outputs: error if no such forward is open or closing fails
+ */
+func (f *forwardings) cancel(kind, spec string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, fw := range f.active {
+		if fw.kind == kind && fw.spec == spec {
+			f.active = append(f.active[:i], f.active[i+1:]...)
+			return fw.closer.Close()
+		}
+	}
+	return fmt.Errorf("unknown forwarding -%s %s", kind, spec)
+}
+
+/*
+* Runs a "~C" command line: "-<kind> spec" opens a forward, "-K<kind> spec" cancels one
+* Inputs: line (string) - command line typed by the user
+* Outputs: error describing an invalid command or a failed forward
+ */
+func (f *forwardings) command(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	option, spec := fields[0], strings.Join(fields[1:], " ")
+	if len(fields) == 1 && len(option) > 2 {
+		// OpenSSH style without a space, e.g. "-L8080:localhost:80"
+		if strings.HasPrefix(option, "-K") {
+			option, spec = option[:3], option[3:]
+		} else {
+			option, spec = option[:2], option[2:]
+		}
+	}
+
+	switch {
+	case option == "?" || option == "help":
+		kinds := f.kinds()
+		if len(kinds) == 0 {
+			return fmt.Errorf("no forwarding types are available")
+		}
+		fmt.Print("Commands:\r\n")
+		for _, kind := range kinds {
+			fmt.Printf("      -%s spec    Request forward\r\n      -K%s spec   Cancel forward\r\n", kind, kind)
+		}
+		return nil
+	case strings.HasPrefix(option, "-K") && len(option) == 3 && spec != "":
+		return f.cancel(option[2:], spec)
+	case strings.HasPrefix(option, "-") && len(option) == 2 && spec != "":
+		return f.open(option[1:], spec)
+	default:
+		return fmt.Errorf("invalid command %q, type ? for help", line)
 	}
-	return shell.Start(command)
+}
+
+/*
+* Returns the forwarding kinds "~C" can open
+* Inputs: none
+* Outputs: []string containing the sorted kinds, e.g. "L", empty if none are registered
+ */
+func (f *forwardings) kinds() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var kinds []string
+	for kind := range f.openers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+/*
+* Returns the number of open forwards
+* Inputs: none
+* Outputs: int containing the count
+ */
+func (f *forwardings) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.active)
+}
+
+/*
+* Describes the open forwards, one per line
+* Inputs: none
+* Outputs: string containing the description
+ */
+func (f *forwardings) describe() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.active) == 0 {
+		return "No forwardings.\n"
+	}
+	var b strings.Builder
+	b.WriteString("The following forwardings are open:\n")
+	for i, fw := range f.active {
+		fmt.Fprintf(&b, "  #%d -%s %s\n", i, fw.kind, fw.spec)
+	}
+	return b.String()
+}
+
+/*
+* Closes every open forward
+* Inputs: none
+* Outputs: none
+ */
+func (f *forwardings) closeAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, fw := range f.active {
+		fw.closer.Close()
+	}
+	f.active = nil
+}
+
+// patternList collects repeated glob flags such as -include
+type patternList []string
+
+/*
+* Returns the collected patterns formatted for flag usage output
+* Inputs: none
+* Outputs: string containing comma-separated patterns
+ */
+func (p *patternList) String() string {
+	return strings.Join(*p, ",")
+}
+
+/*
+* Stores a single glob pattern
+* Inputs: value (string) - flag value
+* Outputs: error, always nil, patterns are validated when the transfer starts
+ */
+func (p *patternList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// forwardSpecs collects repeated forwarding flags such as -L
+type forwardSpecs []string
+
+/*
+* Returns the collected specifications formatted for flag usage output
+* Inputs: none
+* Outputs: string containing comma-separated specifications
+ */
+func (f *forwardSpecs) String() string {
+	return strings.Join(*f, ",")
+}
+
+/*
+* Stores a single forwarding specification
+* Inputs: value (string) - flag value
+* Outputs: error, always nil, specifications are validated when the forward is opened
+ */
+func (f *forwardSpecs) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+/*
+* Parses an OpenSSH style forwarding specification, IPv6 addresses may be written in brackets
+* Either side may be a Unix socket path, which is recognised by containing a "/"
+* Without a bind address the forward listens on localhost, "*" or an empty bind address listens on all interfaces
+* Inputs: spec (string) - specification in format [bind_address:]port:host:hostport, with socket paths in place of [bind_address:]port or host:hostport
+* Outputs: listen and target addresses in host:port or socket path format, error if the specification is invalid
+ */
+func parseForwardSpec(spec string) (string, string, error) {
+	invalid := fmt.Errorf("invalid forwarding %q, use: [bind_address:]port:host:hostport or socket paths", spec)
+
+	var fields []string
+	var field strings.Builder
+	bracket := false
+	for _, r := range spec {
+		switch {
+		case r == '[' && !bracket:
+			bracket = true
+		case r == ']' && bracket:
+			bracket = false
+		case r == ':' && !bracket:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+	fields = append(fields, field.String())
+
+	var target string
+	switch last := len(fields) - 1; {
+	case strings.Contains(fields[last], "/"):
+		target, fields = fields[last], fields[:last]
+	case last >= 2 && fields[last-1] != "" && fields[last] != "":
+		target, fields = net.JoinHostPort(fields[last-1], fields[last]), fields[:last-1]
+	default:
+		return "", "", invalid
+	}
+	// Only the bind address may be empty, like OpenSSH's ":8080:host:80"
+	for i, f := range fields {
+		if f == "" && (i != 0 || len(fields) != 2) {
+			return "", "", invalid
+		}
+	}
+
+	switch {
+	case len(fields) == 1 && strings.Contains(fields[0], "/"):
+		return fields[0], target, nil
+	case len(fields) == 1:
+		return net.JoinHostPort("localhost", fields[0]), target, nil
+	case len(fields) == 2 && (fields[0] == "*" || fields[0] == ""):
+		return net.JoinHostPort("", fields[1]), target, nil
+	case len(fields) == 2:
+		return net.JoinHostPort(fields[0], fields[1]), target, nil
+	default:
+		return "", "", invalid
+	}
+}
+
+/*
+* Parses the listen address of a dynamic forward, without a bind address the proxy listens on localhost
+* Inputs: spec (string) - specification in format [bind_address:]port, or a socket path
+* Outputs: string containing the listen address in host:port or socket path format, error if the specification is invalid
+ */
+func parseDynamicSpec(spec string) (string, error) {
+	if strings.Contains(spec, "/") {
+		return spec, nil
+	}
+	bind, port := "localhost", spec
+	if strings.Contains(spec, ":") {
+		var err error
+		if bind, port, err = net.SplitHostPort(spec); err != nil {
+			return "", fmt.Errorf("invalid forwarding %q, use: [bind_address:]port", spec)
+		}
+		if bind == "*" {
+			bind = ""
+		}
+	}
+	if port == "" {
+		return "", fmt.Errorf("invalid forwarding %q, use: [bind_address:]port", spec)
+	}
+	return net.JoinHostPort(bind, port), nil
+}
+
+/*
+* Keeps the connection open for the forwards until dingo is interrupted or the connection is lost
+* Inputs: client (dingo.SSHClient) - established SSH connection
+* Outputs: error if the connection is lost, nil when interrupted
+ */
+func waitForwarding(client dingo.SSHClient) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	lost := make(chan error, 1)
+	if c, ok := client.(interface{ UnderlyingClient() *ssh.Client }); ok {
+		go func() { lost <- c.UnderlyingClient().Wait() }()
+	}
+
+	fmt.Println("Forwarding... Press Ctrl+C to exit")
+	select {
+	case <-signals:
+		return nil
+	case err := <-lost:
+		return fmt.Errorf("connection lost: %v", err)
+	}
+}
+
+/*
+* Returns the terminal configuration of the local terminal, falling back to the defaults when stdin is not a terminal
+* Inputs: none
+* Outputs: *dingo.TerminalConfig for interactive shells, nil for defaults
+ */
+func localTerminalConfig() *dingo.TerminalConfig {
+	config, err := dingo.LocalTerminalConfig()
+	if err != nil {
+		return nil
+	}
+	return config
 }
 
 /*
@@ -449,19 +1305,132 @@ func handleCommand(client dingo.SSHClient, command string) error {
 	return nil
 }
 
+// jobExitError reports a detached job that finished with a non-zero exit code
+type jobExitError struct {
+	status *dingo.JobStatus
+}
+
+/*
+* Returns a description of the failed job
+* Inputs: none
+* Outputs: string containing the error message
+ */
+func (e *jobExitError) Error() string {
+	return fmt.Sprintf("job %s exited with code %d", e.status.ID, e.status.ExitCode)
+}
+
+/*
+* Starts a command as a detached background job and prints its ID
+* Inputs: client (dingo.SSHClient) - established SSH connection, command (string) - command to run
+* Outputs: error if no command is given or the job cannot be started
+ */
+func handleDetach(client dingo.SSHClient, command string) error {
+	if command == "" {
+		return fmt.Errorf("-detach requires -cmd")
+	}
+
+	job, err := client.StartJob(command)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started job %s\n", job.ID())
+	fmt.Printf("Check it later with: -job %s [-job-logs|-job-wait|-job-cancel]\n", job.ID())
+	return nil
+}
+
+/*
+* Prints the detached jobs on the remote host with their state
+* Inputs: client (dingo.SSHClient) - established SSH connection
+* Outputs: error if the jobs cannot be listed
+ */
+func handleListJobs(client dingo.SSHClient) error {
+	ids, err := client.ListJobs()
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Println("No jobs found.")
+		return nil
+	}
+
+	for _, id := range ids {
+		status, err := client.Job(id).Status()
+		if err != nil {
+			return err
+		}
+		printJobStatus(status)
+	}
+	return nil
+}
+
+/*
+* Shows, follows or cancels a detached job
+* Inputs: client (dingo.SSHClient) - established SSH connection, id (string) - job ID, logs (bool) - print the log tails, wait (bool) - wait for completion, cancel (bool) - terminate the job, lines (int) - log lines to show
+* Outputs: error if the job cannot be reached, *jobExitError if a waited-for job failed
+ */
+func handleJob(client dingo.SSHClient, id string, logs, wait, cancel bool, lines int) error {
+	job := client.Job(id)
+
+	if cancel {
+		if err := job.Cancel(); err != nil {
+			return err
+		}
+		fmt.Printf("Cancelled job %s\n", id)
+	}
+
+	status, err := job.Status()
+	if wait && err == nil && status.State == dingo.JobRunning {
+		fmt.Printf("Waiting for job %s...\n", id)
+		status, err = job.Wait(0)
+	}
+	if err != nil {
+		return err
+	}
+	printJobStatus(status)
+
+	if logs {
+		for _, stream := range []dingo.JobStream{dingo.JobStdout, dingo.JobStderr} {
+			output, err := job.Logs(stream, lines)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("--- %s (last %d lines) ---\n%s", strings.ToUpper(string(stream)), lines, output)
+		}
+	}
+
+	if wait && status.State == dingo.JobFinished && status.ExitCode != 0 {
+		return &jobExitError{status: status}
+	}
+	return nil
+}
+
+/*
+* Prints a one-line summary of a job status
+* Inputs: status (*dingo.JobStatus) - job status to print
+* Outputs: none
+ */
+func printJobStatus(status *dingo.JobStatus) {
+	state := string(status.State)
+	if status.State == dingo.JobFinished {
+		state = fmt.Sprintf("%s (exit %d)", state, status.ExitCode)
+	}
+	fmt.Printf("%-26s %-20s pid %-8d started %s\n", status.ID, state, status.PID, status.StartedAt.Format(time.RFC3339))
+}
+
 /*
 * Handles file tailing operation - monitors a file for changes and displays new content
-* Inputs: client (dingo.SSHClient) - established SSH connection, filename (string) - file to tail, follow (bool) - whether to follow changes, lines (int) - initial lines to show
+* Inputs: client (dingo.SSHClient) - established SSH connection, filename (string) - file to tail, follow (bool) - whether to follow changes, lines (int) - initial lines to show, grace (time.Duration) - grace period for forwarded signals
 * Outputs: error if tail operation fails, nil on completion
  */
-func handleTail(client dingo.SSHClient, filename string, follow bool, lines int) error {
+func handleTail(client dingo.SSHClient, filename string, follow bool, lines int, grace time.Duration) error {
 	fmt.Printf("Tailing file: %s\n", filename)
 
 	if follow {
 		fmt.Printf("Following changes (Ctrl+C to stop)...\n")
-		cmd := client.Command(fmt.Sprintf("tail -f -n %d %s", lines, filename))
+		// A PTY makes the remote tail receive SIGHUP when the connection goes away
+		cmd := client.Command(fmt.Sprintf("tail -f -n %d %s", lines, filename)).WithPTY(nil)
 		cmd.SetStdio(os.Stdout, os.Stderr)
-		return cmd.Run()
+		return runWithSignals(cmd, grace)
 	} else {
 		fmt.Printf("Showing last %d lines:\n", lines)
 		cmd := client.Command(fmt.Sprintf("tail -n %d %s", lines, filename))
@@ -476,18 +1445,17 @@ func handleTail(client dingo.SSHClient, filename string, follow bool, lines int)
 
 /*
 * Handles streaming command execution with separate stdout/stderr display
-* Inputs: client (dingo.SSHClient) - established SSH connection, command (string) - command to execute
+* Inputs: client (dingo.SSHClient) - established SSH connection, command (string) - command to execute, grace (time.Duration) - grace period for forwarded signals
 * Outputs: error if command execution fails, nil on successful execution
-* Notes: sessionName for all sessions is currently just dingo
  */
-func handleStreamCommand(client dingo.SSHClient, command string) error {
+func handleStreamCommand(client dingo.SSHClient, command string, grace time.Duration) error {
 	fmt.Printf("Streaming command: %s\n", command)
 	fmt.Println("--- STDOUT ---")
 
 	cmd := client.Command(command)
 	cmd.SetStdio(os.Stdout, os.Stderr)
 
-	err := cmd.Run()
+	err := runWithSignals(cmd, grace)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "\nCommand failed: %v\n", err)
 		return err
@@ -498,28 +1466,93 @@ func handleStreamCommand(client dingo.SSHClient, command string) error {
 }
 
 /*
-* Checks if the screen utilit is installed on the system
+* Handles restoring (attaching to) an existing persistent session
+* Inputs: session (dingo.PersistentSession) - the session to restore, opts (interactiveOptions) - recording and escape settings
+* Outputs: error if the session does not exist or attaching fails, nil on success
+ */
+func handleRestore(session dingo.PersistentSession, opts interactiveOptions) error {
+	exists, err := session.Exists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("no session named '%s' (use -list-sessions to see the available sessions)", session.Name())
+	}
+
+	multiplexer, _ := session.Multiplexer()
+	detachKeys := "Ctrl+B then D"
+	if multiplexer == dingo.MultiplexerScreen {
+		detachKeys = "Ctrl+A then D"
+	}
+	fmt.Printf("Attempting to restore %s session: %s\n", multiplexer, session.Name())
+	fmt.Printf("This will start an interactive session. Press %s to detach.\n", detachKeys)
+
+	config := localTerminalConfig()
+	return runInteractive(session.Attach(config), config, opts)
+}
+
+/*
+* Prints the persistent tmux/screen sessions on the remote host
 * Inputs: client (dingo.SSHClient) - established SSH connection
-* Outputs: Boolean - True if screen is installed False if not
+* Outputs: error if the sessions cannot be listed
  */
+func handleListSessions(client dingo.SSHClient) error {
+	sessions, err := client.ListSessions()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No sessions found.")
+		return nil
+	}
+
+	for _, session := range sessions {
+		state := "detached"
+		if session.Attached {
+			state = "attached"
+		}
+		fmt.Printf("%-24s %-7s %s\n", session.Name, session.Multiplexer, state)
+	}
+	return nil
+}
 
-func isScreenInstalled(client dingo.SSHClient) bool {
-	// command -v since which is not installed by default
-	output, err := client.Command("command -v screen").Output()
-	return err == nil && len(strings.TrimSpace(string(output))) > 0
+// legacySessionName is the default persistent session name of earlier versions, shared by all local users
+const legacySessionName = "dingo"
+
+/*
+* Returns the session used when -session is not given: the per-user session, or a session left by an earlier
+* version under the shared "dingo" name when only that one is running, so it is not silently orphaned
+* Inputs: client (dingo.SSHClient) - established SSH connection, session (dingo.PersistentSession) - per-user default session
+* Outputs: dingo.PersistentSession to attach to
+ */
+func defaultPersistentSession(client dingo.SSHClient, session dingo.PersistentSession) dingo.PersistentSession {
+	if exists, err := session.Exists(); err != nil || exists {
+		return session
+	}
+	legacy := client.PersistentSession(legacySessionName)
+	if exists, err := legacy.Exists(); err != nil || !exists {
+		return session
+	}
+	fmt.Fprintf(os.Stderr, "Warning: using the existing session '%s' of an earlier version, pass -session %s for a per-user session\n", legacySessionName, session.Name())
+	return legacy
 }
 
 /*
-* Handles restoring (attaching to) a named screen session.
-* Inputs: client (dingo.SSHClient) - established SSH connection, sessionName (string) - the name of the screen session to restore
-* Outputs: error if attaching fails, nil on success
+* Returns the default persistent session name, derived from the local user so operators do not share a session
+* Inputs: none
+* Outputs: string containing a valid session name, e.g. "dingo-alice"
  */
-func handleRestore(client dingo.SSHClient, sessionName string) error {
-	fmt.Printf("Attempting to restore screen session: %s\n", sessionName)
-	fmt.Println("This will start an interactive session. Press Ctrl+A then D to detach.")
+func defaultSessionName() string {
+	current, err := user.Current()
+	if err != nil {
+		return legacySessionName
+	}
 
-	// Use an interactive shell to run 'screen -r' which re-attaches to a session.
-	screenCmd := fmt.Sprintf("screen -r %s", sessionName)
-	shell := client.InteractiveShell(nil)
-	return shell.Start(screenCmd)
+	name := []rune(current.Username)
+	for i, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' || r > unicode.MaxASCII {
+			name[i] = '-'
+		}
+	}
+	return "dingo-" + string(name)
 }
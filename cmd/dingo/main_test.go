@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestParseForwardSpec(t *testing.T) {
+	cases := []struct {
+		spec, listen, target string
+	}{
+		{"8080:db:5432", "localhost:8080", "db:5432"},
+		{"*:8080:db:5432", ":8080", "db:5432"},
+		{":8080:db:5432", ":8080", "db:5432"},
+		{"127.0.0.1:8080:[::1]:80", "127.0.0.1:8080", "[::1]:80"},
+		{"/tmp/app.sock:db:5432", "/tmp/app.sock", "db:5432"},
+		{":8080:/run/app.sock", ":8080", "/run/app.sock"},
+	}
+	for _, c := range cases {
+		listen, target, err := parseForwardSpec(c.spec)
+		if err != nil || listen != c.listen || target != c.target {
+			t.Errorf("%q: got %q, %q, %v, expected %q, %q", c.spec, listen, target, err, c.listen, c.target)
+		}
+	}
+
+	for _, spec := range []string{"", "8080", ":db:5432", "8080::5432", "8080:db:", "a:b::db:5432", "::db:5432"} {
+		if _, _, err := parseForwardSpec(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package dingo
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ErrAgentForwardingEnabled is returned when agent forwarding is enabled twice on the same connection
+var ErrAgentForwardingEnabled = errors.New("agent forwarding is already enabled on this connection")
+
+/*
+* Enables SSH agent forwarding, like ssh -A, for the Command, Script and Shell sessions created after this call
+* Agent forwarding is off by default. Anyone with root on the remote host can use the forwarded agent
+* to authenticate as you while a session is open, so only enable it for hosts you trust
+* Inputs: keyring (agent.Agent) - agent answering the remote requests, e.g. agent.NewClient on $SSH_AUTH_SOCK or agent.NewKeyring()
+* Outputs: error if forwarding was already enabled on this connection
+ */
+func (c *client) EnableAgentForwarding(keyring agent.Agent) error {
+	if c.agent {
+		return ErrAgentForwardingEnabled
+	}
+	if err := agent.ForwardToAgent(c.sshClient, keyring); err != nil {
+		return fmt.Errorf("failed to enable agent forwarding: %w", err)
+	}
+	c.agent = true
+	return nil
+}
+
+/*
+* Internal helper that asks the server to provide the forwarded agent to a session through SSH_AUTH_SOCK
+* Inputs: session (*ssh.Session) - session that has not been started yet
+* Outputs: error if the server refuses agent forwarding
+ */
+func requestAgentForwarding(session *ssh.Session) error {
+	if err := agent.RequestAgentForwarding(session); err != nil {
+		return fmt.Errorf("agent forwarding request failed (check AllowAgentForwarding in sshd_config): %w", err)
+	}
+	return nil
+}
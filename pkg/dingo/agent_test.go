@@ -0,0 +1,170 @@
+package dingo
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+/*
+* Test helper that exposes the client's forwarded agent to server programs on a local Unix socket, like sshd does
+* Inputs: conn (*ssh.ServerConn) - server side of the connection that requested agent forwarding
+* Outputs: string containing the socket path, func removing the socket, error if it cannot be listened on
+ */
+func serveAgentSocket(conn *ssh.ServerConn) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "dingo-agent")
+	if err != nil {
+		return "", nil, err
+	}
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	go func() {
+		for {
+			local, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			channel, requests, err := conn.OpenChannel("auth-agent@openssh.com", nil)
+			if err != nil {
+				local.Close()
+				continue
+			}
+			go ssh.DiscardRequests(requests)
+			go proxyChannel(channel, local)
+		}
+	}()
+	return socket, func() {
+		listener.Close()
+		os.RemoveAll(dir)
+	}, nil
+}
+
+/*
+* Test helper that creates a keyring holding a single new ed25519 key
+* Inputs: t (*testing.T) - test context
+* Outputs: agent.Agent containing the key
+ */
+func createTestKeyring(t *testing.T) agent.Agent {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key, Comment: "deploy-key"}); err != nil {
+		t.Fatalf("Adding key failed: %v", err)
+	}
+	return keyring
+}
+
+/*
+* Test helper that lists the keys of the agent a remote program sees through SSH_AUTH_SOCK
+* Inputs: t (*testing.T) - test context, socket (string) - SSH_AUTH_SOCK printed by the remote program
+* Outputs: []*agent.Key listed by the forwarded agent
+ */
+func listForwardedKeys(t *testing.T, socket string) []*agent.Key {
+	t.Helper()
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("Dialing forwarded agent failed: %v", err)
+	}
+	defer conn.Close()
+
+	keys, err := agent.NewClient(conn).List()
+	if err != nil {
+		t.Fatalf("Listing forwarded keys failed: %v", err)
+	}
+	return keys
+}
+
+func TestAgentForwarding_OffByDefault(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	client := newClient(createExecSSHServer(t), nil)
+
+	output, err := client.Command(`printf '%s' "$SSH_AUTH_SOCK"`).Output()
+	if err != nil || len(output) != 0 {
+		t.Errorf("Expected no forwarded agent, got %q, %v", output, err)
+	}
+}
+
+/*
+* Test helper that runs a remote program printing SSH_AUTH_SOCK and lists the forwarded keys while its session is open
+* Inputs: t (*testing.T) - test context, run (func) - runs the given script on a session writing to stdout
+* Outputs: []*agent.Key listed through the session's SSH_AUTH_SOCK
+ */
+func listSessionKeys(t *testing.T, run func(script string, stdout io.Writer) error) []*agent.Key {
+	t.Helper()
+	// The remote program keeps its session open until the done file exists
+	done := filepath.Join(t.TempDir(), "done")
+	script := fmt.Sprintf(`printf '%%s\n' "$SSH_AUTH_SOCK"; while [ ! -e %s ]; do sleep 0.05; done`, done)
+
+	reader, writer := io.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		result <- run(script, writer)
+		writer.Close()
+	}()
+
+	line, err := bufio.NewReader(reader).ReadString('\n')
+	var keys []*agent.Key
+	if socket := strings.TrimSpace(line); socket != "" {
+		keys = listForwardedKeys(t, socket)
+	} else {
+		t.Errorf("Expected SSH_AUTH_SOCK in the session, got %q, %v", line, err)
+	}
+
+	os.WriteFile(done, nil, 0644)
+	go io.Copy(io.Discard, reader)
+	if err := <-result; err != nil {
+		t.Errorf("Session failed: %v", err)
+	}
+	return keys
+}
+
+func TestAgentForwarding_Sessions(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	client := newClient(createExecSSHServer(t), nil)
+
+	if err := client.EnableAgentForwarding(createTestKeyring(t)); err != nil {
+		t.Fatalf("EnableAgentForwarding failed: %v", err)
+	}
+	if err := client.EnableAgentForwarding(agent.NewKeyring()); !errors.Is(err, ErrAgentForwardingEnabled) {
+		t.Errorf("Expected ErrAgentForwardingEnabled, got %v", err)
+	}
+
+	sessions := map[string]func(script string, stdout io.Writer) error{
+		"Command": func(script string, stdout io.Writer) error {
+			return client.Command(script).SetStdio(stdout, io.Discard).Run()
+		},
+		"Script": func(script string, stdout io.Writer) error {
+			return client.Script(script, WithInterpreter("sh")).SetStdio(stdout, io.Discard).Run()
+		},
+		"Shell": func(script string, stdout io.Writer) error {
+			return client.Shell().SetStdio(strings.NewReader(""), stdout, io.Discard).ShellExec(script)
+		},
+	}
+	for name, run := range sessions {
+		t.Run(name, func(t *testing.T) {
+			keys := listSessionKeys(t, run)
+			if len(keys) != 1 || keys[0].Comment != "deploy-key" {
+				t.Errorf("Unexpected forwarded keys %v", keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package dingo
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Checksum errors, a mismatch found by a verified transfer is returned as a *ChecksumMismatchError
+var (
+	ErrChecksumMismatch    = errors.New("checksum mismatch")
+	ErrUnsupportedChecksum = errors.New("unsupported checksum algorithm")
+)
+
+// ChecksumMismatchError describes a copied file whose destination does not hash to the same value as its source
+type ChecksumMismatchError struct {
+	Source      string
+	Destination string
+	Algorithm   ChecksumAlgorithm
+	Expected    string // Hex digest of the source
+	Actual      string // Hex digest of the destination
+}
+
+/*
+* Returns the mismatch with both paths and digests
+* Inputs: none
+* Outputs: string containing the error message
+ */
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%v: %s of %s is %s, expected %s from %s", ErrChecksumMismatch, e.Algorithm, e.Destination, e.Actual, e.Expected, e.Source)
+}
+
+/*
+* Returns the sentinel error so callers can use errors.Is
+* Inputs: none
+* Outputs: error - ErrChecksumMismatch
+ */
+func (e *ChecksumMismatchError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+/*
+* Computes the checksum of a remote file
+* The hash is computed on the host by the matching *sum command (sha256sum, sha512sum, sha1sum or md5sum),
+* when the command is missing or fails the file is streamed over SFTP and hashed locally
+* Inputs: path (string) - remote file path, algo (ChecksumAlgorithm) - hash to compute, "" for SHA-256
+* Outputs: string containing the lowercase hex digest, error if the file cannot be read or the algorithm is unknown
+ */
+func (rfs *remoteFileSystem) Checksum(path string, algo ChecksumAlgorithm) (string, error) {
+	if rfs.err != nil {
+		return "", rfs.err
+	}
+	return rfs.transferFS().Checksum(path, algo)
+}
+
+/*
+* Computes the checksum of a local file
+* Inputs: name (string) - file path, algo (ChecksumAlgorithm) - hash to compute
+* Outputs: string containing the lowercase hex digest, error if the file cannot be read
+ */
+func (localTransferFS) Checksum(name string, algo ChecksumAlgorithm) (string, error) {
+	return streamChecksum(localTransferFS{}, name, -1, algo)
+}
+
+/*
+* Computes the checksum of the first bytes of a local file
+* Inputs: name (string) - file path, n (int64) - number of bytes to hash, algo (ChecksumAlgorithm) - hash to compute
+* Outputs: string containing the lowercase hex digest, error if the file cannot be read or is shorter than n
+ */
+func (localTransferFS) ChecksumPrefix(name string, n int64, algo ChecksumAlgorithm) (string, error) {
+	return streamChecksum(localTransferFS{}, name, n, algo)
+}
+
+/*
+* Computes the checksum of a remote file with a command on the host, falling back to streaming it over SFTP
+* Inputs: name (string) - file path, algo (ChecksumAlgorithm) - hash to compute
+* Outputs: string containing the lowercase hex digest, error if the file cannot be read
+ */
+func (s sftpTransferFS) Checksum(name string, algo ChecksumAlgorithm) (string, error) {
+	if _, err := newChecksumHash(algo); err != nil {
+		return "", err
+	}
+	if s.ssh != nil {
+		if sum, err := s.commandChecksum(name, -1, algo); err == nil {
+			return sum, nil
+		}
+	}
+	return streamChecksum(s, name, -1, algo)
+}
+
+/*
+* Computes the checksum of the first bytes of a remote file with head and a *sum command on the host,
+* so a large partial file is not read back over SFTP, falling back to streaming it when the commands fail
+* Inputs: name (string) - file path, n (int64) - number of bytes to hash, algo (ChecksumAlgorithm) - hash to compute
+* Outputs: string containing the lowercase hex digest, error if the file cannot be read or is shorter than n
+ */
+func (s sftpTransferFS) ChecksumPrefix(name string, n int64, algo ChecksumAlgorithm) (string, error) {
+	if _, err := newChecksumHash(algo); err != nil {
+		return "", err
+	}
+	if s.ssh != nil {
+		if sum, err := s.commandChecksum(name, n, algo); err == nil {
+			return sum, nil
+		}
+	}
+	return streamChecksum(s, name, n, algo)
+}
+
+/*
+* Internal helper that runs the *sum command of an algorithm on the remote host and parses its output
+* Inputs: name (string) - file path, n (int64) - number of bytes to hash through head -c, -1 for the whole file, algo (ChecksumAlgorithm) - hash to compute, already validated
+* Outputs: string containing the lowercase hex digest, error if the command fails or prints an unexpected digest
+ */
+func (s sftpTransferFS) commandChecksum(name string, n int64, algo ChecksumAlgorithm) (string, error) {
+	if algo == "" {
+		algo = ChecksumSHA256
+	}
+	session, err := s.ssh.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	command := string(algo) + "sum -- " + shellQuote(name)
+	if n >= 0 {
+		// The test makes a missing or unreadable file fail instead of hashing empty input
+		command = fmt.Sprintf("test -r %s && head -c %d -- %s | %ssum", shellQuote(name), n, shellQuote(name), algo)
+	}
+	output, err := session.Output(command)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("no output from %ssum", algo)
+	}
+	// GNU coreutils marks digests of names with special characters with a leading backslash
+	sum := strings.ToLower(strings.TrimPrefix(fields[0], `\`))
+	hash, _ := newChecksumHash(algo)
+	if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != hash.Size() {
+		return "", fmt.Errorf("unexpected output from %ssum: %q", algo, fields[0])
+	}
+	return sum, nil
+}
+
+/*
+* Internal helper that hashes a file by reading it through a transferFS
+* Inputs: fs (transferFS) - filesystem holding the file, name (string) - file path, n (int64) - number of bytes to hash, -1 for the whole file, algo (ChecksumAlgorithm) - hash to compute
+* Outputs: string containing the lowercase hex digest, error if the file cannot be read or the algorithm is unknown
+ */
+func streamChecksum(fs transferFS, name string, n int64, algo ChecksumAlgorithm) (string, error) {
+	hash, err := newChecksumHash(algo)
+	if err != nil {
+		return "", err
+	}
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if n < 0 {
+		_, err = io.CopyBuffer(hash, f, make([]byte, transferBufferSize))
+	} else {
+		_, err = io.CopyN(hash, f, n)
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+/*
+* Internal helper that creates the hash of a checksum algorithm
+* Inputs: algo (ChecksumAlgorithm) - algorithm name, "" for SHA-256
+* Outputs: hash.Hash ready to use, error if the algorithm is unknown
+ */
+func newChecksumHash(algo ChecksumAlgorithm) (hash.Hash, error) {
+	switch algo {
+	case ChecksumSHA256, "":
+		return sha256.New(), nil
+	case ChecksumSHA512:
+		return sha512.New(), nil
+	case ChecksumSHA1:
+		return sha1.New(), nil
+	case ChecksumMD5:
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedChecksum, algo)
+}
+
+/*
+* Internal helper that compares the checksums of a copied file, source and destination are hashed at the same time
+* Inputs: step (transferStep) - file step that has been copied, target (string) - file holding the copy, the temporary file of atomic copies
+* Outputs: *ChecksumMismatchError if the digests differ, error if a file cannot be hashed
+ */
+func (t *treeTransfer) verifyFile(step transferStep, target string) error {
+	var expected string
+	var srcErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		expected, srcErr = t.src.Checksum(step.src, t.config.Checksum)
+	}()
+	actual, dstErr := t.dst.Checksum(target, t.config.Checksum)
+	<-done
+
+	if srcErr != nil {
+		return fmt.Errorf("failed to hash %s: %w", step.src, srcErr)
+	}
+	if dstErr != nil {
+		return fmt.Errorf("failed to hash %s: %w", target, dstErr)
+	}
+	if expected != actual {
+		algo := t.config.Checksum
+		if algo == "" {
+			algo = ChecksumSHA256
+		}
+		return &ChecksumMismatchError{Source: step.src, Destination: step.dst, Algorithm: algo, Expected: expected, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package dingo
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoteFileSystem_Checksum(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	file := filepath.Join(t.TempDir(), "model's weights.bin")
+	data := writeRandomFile(t, file, 3<<20)
+
+	sha256Sum := sha256.Sum256(data)
+	sha512Sum := sha512.Sum512(data)
+	sha1Sum := sha1.Sum(data)
+	md5Sum := md5.Sum(data)
+	expected := map[ChecksumAlgorithm]string{
+		"":             hex.EncodeToString(sha256Sum[:]),
+		ChecksumSHA256: hex.EncodeToString(sha256Sum[:]),
+		ChecksumSHA512: hex.EncodeToString(sha512Sum[:]),
+		ChecksumSHA1:   hex.EncodeToString(sha1Sum[:]),
+		ChecksumMD5:    hex.EncodeToString(md5Sum[:]),
+	}
+	remote := fs.(*remoteFileSystem).transferFS()
+	streamed := sftpTransferFS{client: remote.client}
+	for algo, want := range expected {
+		if got, err := fs.Checksum(file, algo); err != nil || got != want {
+			t.Errorf("Checksum(%q) = %q, %v, expected %q", algo, got, err, want)
+		}
+		if got, err := remote.commandChecksum(file, -1, algo); err != nil || got != want {
+			t.Errorf("command Checksum(%q) = %q, %v, expected %q", algo, got, err, want)
+		}
+		if got, err := streamed.Checksum(file, algo); err != nil || got != want {
+			t.Errorf("streamed Checksum(%q) = %q, %v, expected %q", algo, got, err, want)
+		}
+	}
+
+	if _, err := fs.Checksum(file, "crc32"); !errors.Is(err, ErrUnsupportedChecksum) {
+		t.Errorf("Expected ErrUnsupportedChecksum, got %v", err)
+	}
+	if _, err := fs.Checksum(filepath.Join(t.TempDir(), "missing"), ChecksumSHA256); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+}
+
+func TestSftpTransferFS_ChecksumPrefix(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	file := filepath.Join(t.TempDir(), "model's weights.bin")
+	data := writeRandomFile(t, file, 1<<20)
+
+	sum := sha256.Sum256(data[:300000])
+	want := hex.EncodeToString(sum[:])
+	remote := fs.(*remoteFileSystem).transferFS()
+	if got, err := remote.commandChecksum(file, 300000, ChecksumSHA256); err != nil || got != want {
+		t.Errorf("command prefix = %q, %v, expected %q", got, err, want)
+	}
+	if got, err := (sftpTransferFS{client: remote.client}).ChecksumPrefix(file, 300000, ChecksumSHA256); err != nil || got != want {
+		t.Errorf("streamed prefix = %q, %v, expected %q", got, err, want)
+	}
+	if got, err := (localTransferFS{}).ChecksumPrefix(file, 300000, ChecksumSHA256); err != nil || got != want {
+		t.Errorf("local prefix = %q, %v, expected %q", got, err, want)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	if _, err := remote.commandChecksum(missing, 10, ChecksumSHA256); err == nil {
+		t.Error("Expected the command to fail for a missing file")
+	}
+	if _, err := remote.ChecksumPrefix(missing, 10, ChecksumSHA256); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+}
+
+func TestRemoteFileSystem_Checksum_WithError(t *testing.T) {
+	expectedErr := errors.New("sftp error")
+	fs := &remoteFileSystem{err: expectedErr}
+	if _, err := fs.Checksum("/tmp/file", ChecksumSHA256); err != expectedErr {
+		t.Errorf("Expected %v, got %v", expectedErr, err)
+	}
+}
+
+func TestTransfer_Verify(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	local := filepath.Join(t.TempDir(), "model.bin")
+	data := writeRandomFile(t, local, 1<<20)
+	remote := filepath.Join(t.TempDir(), "model.bin")
+
+	if err := fs.Upload(local, remote, WithVerify(true)); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	back := filepath.Join(t.TempDir(), "model.bin")
+	if err := fs.Download(remote, back, WithVerify(true), WithChecksum(ChecksumMD5)); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if got, _ := os.ReadFile(back); string(got) != string(data) {
+		t.Error("Downloaded data differs from the upload")
+	}
+
+	dst := filepath.Join(t.TempDir(), "upload")
+	if err := fs.UploadDir(createTransferTree(t), dst, WithVerify(true), WithSymlinks(SymlinkSkip)); err != nil {
+		t.Fatalf("UploadDir failed: %v", err)
+	}
+	checkTransferredFile(t, dst, "weights/model.bin", 0644)
+
+	if err := fs.Upload(local, remote, WithVerify(true), WithChecksum("crc32")); !errors.Is(err, ErrUnsupportedChecksum) {
+		t.Errorf("Expected ErrUnsupportedChecksum, got %v", err)
+	}
+}
+
+func TestTransfer_VerifyMismatch(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	local := filepath.Join(t.TempDir(), "model.bin")
+	writeRandomFile(t, local, 64<<10)
+
+	// A complete partial file of a resumed copy is trusted by resume, verification catches that it differs
+	remote := filepath.Join(t.TempDir(), "model.bin")
+	corrupt := writeRandomFile(t, partialName(remote), 64<<10)
+	err := fs.Upload(local, remote, WithResume(true), WithVerify(true))
+
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) || !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Expected ChecksumMismatchError, got %v", err)
+	}
+	if mismatch.Source != local || mismatch.Destination != remote || mismatch.Algorithm != ChecksumSHA256 {
+		t.Errorf("Unexpected mismatch details: %+v", mismatch)
+	}
+	if sum := sha256.Sum256(corrupt); mismatch.Actual != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected actual digest of the corrupt file, got %s", mismatch.Actual)
+	}
+	if mismatch.Expected == mismatch.Actual {
+		t.Error("Expected digests to differ")
+	}
+	for _, name := range []string{remote, partialName(remote)} {
+		if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected the mismatching copy to be discarded, %s: %v", name, err)
+		}
+	}
+}
+
+// corruptingFS is a local transferFS whose temporary files of atomic copies hash to a wrong digest
+type corruptingFS struct {
+	localTransferFS
+}
+
+func (corruptingFS) Checksum(name string, algo ChecksumAlgorithm) (string, error) {
+	if strings.HasSuffix(name, ".tmp") {
+		return "corrupt", nil
+	}
+	return localTransferFS{}.Checksum(name, algo)
+}
+
+func TestTransfer_AtomicVerifyMismatch(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "model.bin")
+	writeRandomFile(t, src, 64<<10)
+	info, err := os.Stat(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "model.bin")
+	previous := writeRandomFile(t, dst, 1<<10)
+
+	config := &TransferConfig{Atomic: true, Verify: true}
+	transfer := &treeTransfer{src: localTransferFS{}, dst: corruptingFS{}, config: config, sftp: &SftpConfig{}}
+	step := transferStep{kind: stepFile, src: src, dst: dst, info: info}
+	err = transfer.copyFile(step, newTransferTracker(config, 1, info.Size()))
+
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Destination != dst {
+		t.Fatalf("Expected ChecksumMismatchError for %s, got %v", dst, err)
+	}
+	// The copy that failed verification never replaces the destination
+	if got, _ := os.ReadFile(dst); string(got) != string(previous) {
+		t.Error("Expected the previous destination to be kept")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected the temporary file to be removed, found %d entries", len(entries))
+	}
+}
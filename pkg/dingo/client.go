@@ -12,8 +12,10 @@ import (
 type client struct {
 	sshClient  *ssh.Client
 	sftpClient *sftp.Client // Single SFTP session instead of sync.Map
+	sftpConfig SftpConfig   // Configuration sftpClient was created with
 	config     *ClientConfig
 	status     ConnectionStatus
+	agent      bool // Sessions request agent forwarding, see EnableAgentForwarding
 }
 
 /*
@@ -40,38 +42,56 @@ func newClient(sshClient *ssh.Client, config *ClientConfig) SSHClient {
  */
 func (c *client) Command(cmd string) CommandExecutor {
 	return &remoteScript{
-		client:     c.sshClient,
-		scriptType: CommandLine,
-		script:     cmd,
+		client:       c.sshClient,
+		scriptType:   CommandLine,
+		script:       cmd,
+		forwardAgent: c.agent,
 	}
 }
 
 /*
-* Creates a CommandExecutor for executing a raw shell script on the remote server
-* Inputs: script (string) - the shell script content to execute
+* Creates a CommandExecutor for executing a raw script on the remote server
+* Inputs: script (string) - the script content to execute, opts (...ScriptOption) - interpreter, argument and upload options
 * Outputs: CommandExecutor interface for running the script
  */
-func (c *client) Script(script string) CommandExecutor {
+func (c *client) Script(script string, opts ...ScriptOption) CommandExecutor {
 	return &remoteScript{
-		client:     c.sshClient,
-		scriptType: RawScript,
-		script:     script,
+		client:       c.sshClient,
+		scriptType:   RawScript,
+		script:       script,
+		scriptConfig: newScriptConfig(opts),
+		forwardAgent: c.agent,
 	}
 }
 
 /*
 * Creates a CommandExecutor for executing a script file on the remote server
-* Inputs: path (string) - local path to the script file to execute
+* Inputs: path (string) - local path to the script file to execute, opts (...ScriptOption) - interpreter, argument and upload options
 * Outputs: CommandExecutor interface for running the script file
  */
-func (c *client) ScriptFile(path string) CommandExecutor {
+func (c *client) ScriptFile(path string, opts ...ScriptOption) CommandExecutor {
 	return &remoteScript{
-		client:     c.sshClient,
-		scriptType: ScriptFile,
-		scriptFile: path,
+		client:       c.sshClient,
+		scriptType:   ScriptFile,
+		scriptFile:   path,
+		scriptConfig: newScriptConfig(opts),
+		forwardAgent: c.agent,
 	}
 }
 
+/*
+* Internal helper that builds a script configuration from the defaults and the given options
+* Inputs: opts ([]ScriptOption) - script options to apply
+* Outputs: *ScriptConfig containing the resulting configuration
+ */
+func newScriptConfig(opts []ScriptOption) *ScriptConfig {
+	config := *DefaultScriptConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return &config
+}
+
 /*
 * Creates a non-interactive shell session without PTY support
 * Inputs: none
@@ -79,9 +99,10 @@ func (c *client) ScriptFile(path string) CommandExecutor {
  */
 func (c *client) Shell() Shell {
 	return &remoteShell{
-		client:     c.sshClient,
-		shellType:  NonInteractiveShell,
-		requestPty: false,
+		client:       c.sshClient,
+		shellType:    NonInteractiveShell,
+		requestPty:   false,
+		forwardAgent: c.agent,
 	}
 }
 
@@ -100,51 +121,89 @@ func (c *client) InteractiveShell(config *TerminalConfig) Shell {
 		shellType:      InteractiveShell,
 		requestPty:     true,
 		terminalConfig: config,
+		forwardAgent:   c.agent,
 	}
 }
 
 /*
 * Creates a FileSystem interface for SFTP operations with optional configuration
+* The SFTP session is shared between FileSystems, options that change the packet size, the concurrent requests
+* or the use of fstat open a separate session that is closed with the FileSystem
 * Inputs: opts (...SftpOption) - variadic SFTP configuration options
 * Outputs: FileSystem interface for remote file operations
  */
 func (c *client) FileSystem(opts ...SftpOption) FileSystem {
 	// Apply configuration options first
-	config := &SftpConfig{}
+	config := *DefaultSftpConfig
 	for _, opt := range opts {
-		opt(config)
+		opt(&config)
 	}
 
-	// Create SFTP client if not already created
-	if c.sftpClient == nil {
-		if c.sshClient == nil {
-			return &remoteFileSystem{
-				client: c.sshClient,
-				sftp:   nil,
-				config: config,
-				err:    fmt.Errorf("SSH client is nil"),
-			}
+	if c.sshClient == nil {
+		return &remoteFileSystem{
+			client: c.sshClient,
+			sftp:   nil,
+			config: &config,
+			err:    fmt.Errorf("SSH client is nil"),
 		}
+	}
+
+	// Create SFTP client if not already created
+	sftpClient := c.sftpClient
+	if sftpClient == nil || !sameSftpSession(&c.sftpConfig, &config) {
 		var err error
-		c.sftpClient, err = sftp.NewClient(c.sshClient)
+		sftpClient, err = sftp.NewClient(c.sshClient, sftpClientOptions(&config)...)
 		if err != nil {
 			return &remoteFileSystem{
 				client: c.sshClient,
 				sftp:   nil,
-				config: config,
+				config: &config,
 				err:    err,
 			}
 		}
+		if c.sftpClient == nil {
+			c.sftpClient = sftpClient
+			c.sftpConfig = config
+		}
 	}
 
 	return &remoteFileSystem{
 		client: c.sshClient,
-		sftp:   c.sftpClient,
-		config: config,
+		sftp:   sftpClient,
+		config: &config,
 		err:    nil,
 	}
 }
 
+/*
+* Internal helper that converts an SFTP configuration into options for the SFTP session
+* Concurrent writes are enabled because interrupted copies are truncated to the data known to be written
+* Inputs: config (*SftpConfig) - SFTP configuration
+* Outputs: []sftp.ClientOption for sftp.NewClient
+ */
+func sftpClientOptions(config *SftpConfig) []sftp.ClientOption {
+	options := []sftp.ClientOption{
+		sftp.UseFstat(config.UseFstat),
+		sftp.UseConcurrentWrites(true),
+	}
+	if config.MaxPacket > 0 {
+		options = append(options, sftp.MaxPacketUnchecked(config.MaxPacket))
+	}
+	if config.ConcurrentRequests > 0 {
+		options = append(options, sftp.MaxConcurrentRequestsPerFile(config.ConcurrentRequests))
+	}
+	return options
+}
+
+/*
+* Internal helper that reports whether two configurations can share an SFTP session
+* Inputs: a (*SftpConfig) - first configuration, b (*SftpConfig) - second configuration
+* Outputs: bool reporting whether the session options are equal
+ */
+func sameSftpSession(a, b *SftpConfig) bool {
+	return a.MaxPacket == b.MaxPacket && a.UseFstat == b.UseFstat && a.ConcurrentRequests == b.ConcurrentRequests
+}
+
 /*
 * Closes the SSH connection and all associated resources including SFTP sessions
 * Inputs: none
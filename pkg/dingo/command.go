@@ -2,12 +2,17 @@ package dingo
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"strings"
+	"time"
 
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -19,6 +24,13 @@ type remoteScript struct {
 	scriptFile string
 	err        error
 
+	scriptConfig   *ScriptConfig
+	sudoConfig     *SudoConfig
+	terminalConfig *TerminalConfig
+	signals        <-chan os.Signal
+	signalGrace    time.Duration
+	forwardAgent   bool
+
 	stdout io.Writer
 	stderr io.Writer
 }
@@ -148,6 +160,34 @@ func (rs *remoteScript) Cmd(cmd string) CommandExecutor {
 	return rs
 }
 
+/*
+* Runs the command or script with elevated privileges through sudo, su or doas
+* Inputs: opts (...SudoOption) - escalation method, target user and credential options
+* Outputs: CommandExecutor interface for method chaining
+ */
+func (rs *remoteScript) Sudo(opts ...SudoOption) CommandExecutor {
+	config := *DefaultSudoConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	rs.sudoConfig = &config
+	return rs
+}
+
+/*
+* Runs the command or script on a pseudo-terminal, for programs that require a TTY
+* Stdout and stderr are merged by the remote terminal and delivered on stdout
+* Inputs: config (*TerminalConfig) - terminal configuration or nil for defaults
+* Outputs: CommandExecutor interface for method chaining
+ */
+func (rs *remoteScript) WithPTY(config *TerminalConfig) CommandExecutor {
+	if config == nil {
+		config = DefaultTerminalConfig
+	}
+	rs.terminalConfig = config
+	return rs
+}
+
 /*
 * Internal helper that executes multiple commands sequentially, one per line
 * Inputs: none (uses internal script string)
@@ -176,39 +216,172 @@ func (rs *remoteScript) runCommands() error {
 * Outputs: error if command execution fails, nil on success
  */
 func (rs *remoteScript) runSingleCommand(cmd string) error {
+	return rs.execute(cmd, nil)
+}
+
+/*
+* Internal helper that executes a raw script by piping its content into the login shell or the configured interpreter
+* With a PTY the script is uploaded and executed instead, a terminal echoes its input and only ends it on ^D
+* Inputs: none (uses internal script string and script configuration)
+* Outputs: error if script execution fails, nil on success
+ */
+func (rs *remoteScript) runScript() error {
+	if rs.terminalConfig != nil && rs.scriptConfig == nil {
+		rs.scriptConfig = newScriptConfig(nil)
+	}
+	if rs.terminalConfig != nil || (rs.scriptConfig != nil && rs.scriptConfig.UploadExecute) {
+		return rs.runUploadedScript()
+	}
+
+	return rs.execute(rs.stdinCommand(), strings.NewReader(rs.script))
+}
+
+/*
+* Internal helper that runs a command in a new SSH session, escalating privileges if Sudo() was requested
+* Inputs: command (string) - remote command line, "" to start the login shell, stdin (io.Reader) - input for the command or nil
+* Outputs: error if session creation or execution fails, nil on success
+ */
+func (rs *remoteScript) execute(command string, stdin io.Reader) error {
 	session, err := rs.client.NewSession()
 	if err != nil {
 		return err
 	}
 	defer session.Close()
 
+	if rs.forwardAgent {
+		if err := requestAgentForwarding(session); err != nil {
+			return err
+		}
+	}
+
+	if rs.sudoConfig != nil {
+		return rs.runElevated(session, command, stdin)
+	}
+
+	session.Stdin = stdin
 	session.Stdout = rs.stdout
 	session.Stderr = rs.stderr
 
-	return session.Run(cmd)
+	if rs.terminalConfig != nil {
+		if err := requestPty(session, rs.terminalConfig); err != nil {
+			return err
+		}
+	}
+
+	if command == "" {
+		err = session.Shell()
+	} else {
+		err = session.Start(command)
+	}
+	if err != nil {
+		return err
+	}
+
+	return rs.wait(session)
 }
 
 /*
-* Internal helper that executes a raw script by starting a shell and piping the script content
-* Inputs: none (uses internal script string)
-* Outputs: error if script execution fails, nil on success
+* Internal helper that uploads the script to a temporary remote file, executes it and removes it again
+* Inputs: none (uses internal script string and script configuration)
+* Outputs: error if upload, execution or cleanup fails, nil on success
  */
-func (rs *remoteScript) runScript() error {
-	session, err := rs.client.NewSession()
+func (rs *remoteScript) runUploadedScript() error {
+	sftpClient, err := sftp.NewClient(rs.client)
 	if err != nil {
 		return err
 	}
-	defer session.Close()
+	defer sftpClient.Close()
 
-	session.Stdin = strings.NewReader(rs.script)
-	session.Stdout = rs.stdout
-	session.Stderr = rs.stderr
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	remotePath := path.Join(rs.scriptConfig.RemoteDir, "dingo-script-"+hex.EncodeToString(suffix))
 
-	if err := session.Shell(); err != nil {
+	f, err := sftpClient.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
 		return err
 	}
+	_, err = f.Write([]byte(rs.script))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = sftpClient.Chmod(remotePath, 0700)
+	}
+	if err != nil {
+		sftpClient.Remove(remotePath)
+		return err
+	}
+
+	runErr := rs.runSingleCommand(rs.fileCommand(remotePath))
+	if err := sftpClient.Remove(remotePath); err != nil && runErr == nil {
+		return err
+	}
+	return runErr
+}
 
-	return session.Wait()
+/*
+* Internal helper that builds the command reading the script from stdin, or "" to use the login shell
+* Inputs: none (uses internal script string and script configuration)
+* Outputs: string containing the remote command line
+ */
+func (rs *remoteScript) stdinCommand() string {
+	interpreter := rs.interpreter()
+	var args []string
+	if rs.scriptConfig != nil {
+		args = rs.scriptConfig.Args
+	}
+
+	if interpreter == "" {
+		if len(args) == 0 {
+			return "" // Preserve the plain login shell behaviour
+		}
+		interpreter = "sh"
+	}
+
+	// Shells take the script from stdin with -s, most other interpreters with -
+	command := interpreter + " -"
+	if isShellInterpreter(interpreter) {
+		command = interpreter + " -s"
+		if len(args) > 0 {
+			command += " --"
+		}
+	}
+	return joinCommand(command, args)
+}
+
+/*
+* Internal helper that builds the command executing an uploaded script file
+* Inputs: remotePath (string) - path of the uploaded script on the remote server
+* Outputs: string containing the remote command line
+ */
+func (rs *remoteScript) fileCommand(remotePath string) string {
+	command := shellQuote(remotePath)
+	if interpreter := rs.scriptConfig.Interpreter; interpreter != "" {
+		command = interpreter + " " + command
+	} else if shebangInterpreter(rs.script) == "" {
+		command = "sh " + command
+	}
+	return joinCommand(command, rs.scriptConfig.Args)
+}
+
+/*
+* Internal helper that resolves the interpreter from the explicit setting or the script's shebang
+* Inputs: none (uses internal script string and script configuration)
+* Outputs: string containing the interpreter command, "" if none is configured
+ */
+func (rs *remoteScript) interpreter() string {
+	if rs.scriptConfig == nil {
+		return ""
+	}
+	if rs.scriptConfig.Interpreter != "" {
+		return rs.scriptConfig.Interpreter
+	}
+	if rs.scriptConfig.UseShebang {
+		return shebangInterpreter(rs.script)
+	}
+	return ""
 }
 
 /*
@@ -244,3 +417,69 @@ func (rs *remoteScript) runScriptFile() error {
 
 	return err
 }
+
+/*
+* Extracts the interpreter command from a script's #! line
+* Inputs: script (string) - script content
+* Outputs: string containing the interpreter and its arguments, "" if the script has no shebang
+ */
+func shebangInterpreter(script string) string {
+	if !strings.HasPrefix(script, "#!") {
+		return ""
+	}
+	line := script[2:]
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+/*
+* Reports whether an interpreter command runs a POSIX-style shell that reads scripts from stdin with -s
+* Inputs: interpreter (string) - interpreter command, possibly prefixed with env and followed by flags
+* Outputs: bool - true for sh, bash, dash, ksh and zsh
+ */
+func isShellInterpreter(interpreter string) bool {
+	fields := strings.Fields(interpreter)
+	if len(fields) == 0 {
+		return false
+	}
+	program := path.Base(fields[0])
+	if program == "env" {
+		program = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				program = path.Base(field)
+				break
+			}
+		}
+	}
+
+	switch program {
+	case "sh", "bash", "dash", "ksh", "zsh":
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+* Quotes a string so the remote shell passes it through as a single literal word
+* Inputs: s (string) - value to quote
+* Outputs: string containing the single-quoted value
+ */
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+/*
+* Appends shell-quoted arguments to a command line
+* Inputs: command (string) - command line prefix, args ([]string) - arguments to quote and append
+* Outputs: string containing the complete command line
+ */
+func joinCommand(command string, args []string) string {
+	for _, arg := range args {
+		command += " " + shellQuote(arg)
+	}
+	return command
+}
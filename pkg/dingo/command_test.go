@@ -2,12 +2,21 @@ package dingo
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
 
+	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -126,6 +135,240 @@ func (m *mockSSHClientForCommand) Conn() ssh.Conn {
 	return nil
 }
 
+/*
+* Test helper that starts an in-process SSH server executing exec and shell requests with the local /bin/sh
+* Inputs: t (*testing.T) - test context
+* Outputs: *ssh.Client connected to the server, closed automatically when the test finishes
+ */
+func createExecSSHServer(t *testing.T) *ssh.Client {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	hostKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatalf("failed to create host signer: %v", err)
+	}
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start listener: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return // Server closed
+			}
+			go serveExecConn(conn, config)
+		}
+	}()
+
+	client, err := ssh.Dial("tcp", listener.Addr().String(), &ssh.ClientConfig{
+		User:            "testuser",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		listener.Close()
+		t.Fatalf("failed to connect to exec server: %v", err)
+	}
+
+	t.Cleanup(func() {
+		client.Close()
+		listener.Close()
+	})
+	return client
+}
+
+// serveExecConn handles the session channels of a single test server connection
+func serveExecConn(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+
+	serverConn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go serveGlobalRequests(serverConn, reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() == "direct-tcpip" || newChannel.ChannelType() == "direct-streamlocal@openssh.com" {
+			go serveDirectChannel(newChannel)
+			continue
+		}
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go serveExecSession(serverConn, channel, requests)
+	}
+}
+
+// serveExecSession runs the program requested on a session channel and reports its exit status
+// A pty-req is not backed by a real terminal, it is reported to the program as TERM and DINGO_TEST_PTY
+// and the input is echoed to stdout unless the ECHO mode is turned off, like a terminal does
+func serveExecSession(conn *ssh.ServerConn, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	var env []string
+	echo := false
+	for req := range requests {
+		switch req.Type {
+		case "auth-agent-req@openssh.com":
+			socket, closeSocket, err := serveAgentSocket(conn)
+			if err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			defer closeSocket()
+			env = append(env, "SSH_AUTH_SOCK="+socket)
+			req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			runExecCommand(channel, exec.Command("sh", "-c", payload.Command), env, echo, requests)
+			return
+		case "shell":
+			req.Reply(true, nil)
+			runExecCommand(channel, exec.Command("sh"), env, echo, requests)
+			return
+		case "pty-req":
+			var payload struct {
+				Term          string
+				Columns, Rows uint32
+				Width, Height uint32
+				Modes         string
+			}
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			env = append(env, "TERM="+payload.Term, fmt.Sprintf("DINGO_TEST_PTY=%dx%d", payload.Columns, payload.Rows))
+			echo = terminalModeEnabled(payload.Modes, ssh.ECHO)
+			req.Reply(true, nil)
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go ssh.DiscardRequests(requests)
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+			server.Serve()
+			server.Close()
+			return
+		default:
+			if req.WantReply {
+				req.Reply(true, nil)
+			}
+		}
+	}
+}
+
+// terminalModeEnabled reports whether an encoded terminal mode list leaves a flag on, flags missing from the list are on
+func terminalModeEnabled(modes string, opcode uint8) bool {
+	for i := 0; i+5 <= len(modes) && modes[i] != 0; i += 5 {
+		if modes[i] == opcode {
+			return binary.BigEndian.Uint32([]byte(modes[i+1:i+5])) != 0
+		}
+	}
+	return true
+}
+
+// testSignals maps SSH signal names to the local signals delivered by the exec server
+var testSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+// runExecCommand connects a local process to the channel, relays signal requests and reports how the process exited
+func runExecCommand(channel ssh.Channel, cmd *exec.Cmd, env []string, echo bool, requests <-chan *ssh.Request) {
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = channel
+	cmd.Stderr = channel.Stderr()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{127}))
+		return
+	}
+	go func() {
+		var input io.Reader = channel
+		if echo {
+			input = io.TeeReader(channel, channel)
+		}
+		io.Copy(stdin, input)
+		stdin.Close()
+	}()
+	go func() {
+		for req := range requests {
+			if req.Type == "window-change" {
+				// Report the new size on stderr so tests can observe it
+				var payload struct{ Columns, Rows, Width, Height uint32 }
+				if ssh.Unmarshal(req.Payload, &payload) == nil {
+					fmt.Fprintf(channel.Stderr(), "window-change %dx%d\n", payload.Columns, payload.Rows)
+				}
+			}
+			if req.Type == "signal" {
+				var payload struct{ Signal string }
+				if ssh.Unmarshal(req.Payload, &payload) == nil {
+					if sig, ok := testSignals[payload.Signal]; ok {
+						cmd.Process.Signal(sig)
+					}
+				}
+			}
+			if req.WantReply {
+				req.Reply(true, nil)
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			for name, sig := range testSignals {
+				if sig == status.Signal() {
+					channel.SendRequest("exit-signal", false, ssh.Marshal(struct {
+						Signal     string
+						CoreDumped bool
+						Error      string
+						Lang       string
+					}{Signal: name}))
+					return
+				}
+			}
+		}
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(exitErr.ExitCode())}))
+		return
+	}
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+}
+
 // Test helper to create remoteScript with mock client
 func createTestRemoteScript(scriptType ScriptType, script, scriptFile string, shouldFail bool) *remoteScript {
 	return &remoteScript{
@@ -916,3 +1159,223 @@ func TestRemoteScript_Output_EarlyErrorReturn(t *testing.T) {
 		t.Error("Original stdout should be unchanged on early error return")
 	}
 }
+
+func TestShebangInterpreter(t *testing.T) {
+	tests := map[string]string{
+		"#!/bin/bash\necho hi":           "/bin/bash",
+		"#! /usr/bin/env python3\nprint": "/usr/bin/env python3",
+		"#!/bin/sh -e":                   "/bin/sh -e",
+		"echo no shebang":                "",
+		"":                               "",
+	}
+
+	for script, expected := range tests {
+		if got := shebangInterpreter(script); got != expected {
+			t.Errorf("shebangInterpreter(%q) = %q, expected %q", script, got, expected)
+		}
+	}
+}
+
+func TestIsShellInterpreter(t *testing.T) {
+	tests := map[string]bool{
+		"sh":                          true,
+		"/bin/bash -e":                true,
+		"/usr/bin/env zsh":            true,
+		"/usr/bin/env -S LANG=C bash": true,
+		"python3":                     false,
+		"/usr/bin/env python3":        false,
+		"perl -w":                     false,
+		"":                            false,
+	}
+
+	for interpreter, expected := range tests {
+		if got := isShellInterpreter(interpreter); got != expected {
+			t.Errorf("isShellInterpreter(%q) = %v, expected %v", interpreter, got, expected)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"simple":     "'simple'",
+		"with space": "'with space'",
+		"it's":       `'it'\''s'`,
+		"$(whoami)":  "'$(whoami)'",
+		"":           "''",
+	}
+
+	for input, expected := range tests {
+		if got := shellQuote(input); got != expected {
+			t.Errorf("shellQuote(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestRemoteScript_StdinCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		opts     []ScriptOption
+		expected string
+	}{
+		{"no options uses login shell", "echo hi", nil, ""},
+		{"args without interpreter use sh", "echo $1", []ScriptOption{WithArgs("a b")}, "sh -s -- 'a b'"},
+		{"explicit interpreter", "print(1)", []ScriptOption{WithInterpreter("python3")}, "python3 -"},
+		{"explicit shell with args", "echo $1", []ScriptOption{WithInterpreter("bash"), WithArgs("x")}, "bash -s -- 'x'"},
+		{"shebang ignored by default", "#!/usr/bin/perl\nprint 1", nil, ""},
+		{"shebang honoured", "#!/usr/bin/perl\nprint 1", []ScriptOption{WithShebang(true), WithArgs("1")}, "/usr/bin/perl - '1'"},
+		{"interpreter overrides shebang", "#!/bin/bash\n", []ScriptOption{WithShebang(true), WithInterpreter("dash")}, "dash -s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := &remoteScript{scriptType: RawScript, script: tt.script, scriptConfig: newScriptConfig(tt.opts)}
+			if got := rs.stdinCommand(); got != tt.expected {
+				t.Errorf("Expected command %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRemoteScript_FileCommand(t *testing.T) {
+	rs := &remoteScript{script: "echo hi", scriptConfig: newScriptConfig([]ScriptOption{WithArgs("a")})}
+	if got := rs.fileCommand("/tmp/s"); got != "sh '/tmp/s' 'a'" {
+		t.Errorf("Unexpected command for script without shebang: %q", got)
+	}
+
+	rs.script = "#!/bin/bash\necho hi"
+	if got := rs.fileCommand("/tmp/s"); got != "'/tmp/s' 'a'" {
+		t.Errorf("Unexpected command for script with shebang: %q", got)
+	}
+
+	rs.scriptConfig.Interpreter = "python3"
+	if got := rs.fileCommand("/tmp/s"); got != "python3 '/tmp/s' 'a'" {
+		t.Errorf("Unexpected command for explicit interpreter: %q", got)
+	}
+}
+
+func TestRemoteScript_Script_WithArgs(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	output, err := client.Script(`echo "$#:$1:$2"`, WithArgs("first arg", "it's")).Output()
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "2:first arg:it's" {
+		t.Errorf("Unexpected script output: %q", got)
+	}
+}
+
+func TestRemoteScript_ScriptFile_Shebang(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	client := newClient(createExecSSHServer(t), nil)
+
+	scriptPath := filepath.Join(t.TempDir(), "script.sh")
+	script := "#!/usr/bin/env bash\necho \"${BASH_VERSION:+bash}:$1\"\n"
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	output, err := client.ScriptFile(scriptPath, WithShebang(true), WithArgs("ok")).Output()
+	if err != nil {
+		t.Fatalf("ScriptFile failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "bash:ok" {
+		t.Errorf("Unexpected script output: %q", got)
+	}
+}
+
+func TestRemoteScript_Script_UploadExecute(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+	remoteDir := t.TempDir()
+
+	output, err := client.Script("echo \"$0 $1\"", WithUploadExecute(remoteDir), WithArgs("arg")).Output()
+	if err != nil {
+		t.Fatalf("Upload-execute script failed: %v", err)
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 || filepath.Dir(fields[0]) != remoteDir || fields[1] != "arg" {
+		t.Errorf("Unexpected script output: %q", output)
+	}
+
+	entries, err := os.ReadDir(remoteDir)
+	if err != nil {
+		t.Fatalf("Failed to read remote dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected uploaded script to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestRemoteScript_Script_ExitStatus(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	err := client.Script("exit 3", WithArgs("x")).Run()
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 3 {
+		t.Errorf("Expected exit status 3, got %v", err)
+	}
+}
+
+func TestRemoteScript_WithPTY(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	output, err := client.Command(`echo "$TERM $DINGO_TEST_PTY"`).WithPTY(&TerminalConfig{
+		Term:   "vt100",
+		Width:  132,
+		Height: 50,
+		Modes:  ssh.TerminalModes{},
+	}).Output()
+	if err != nil {
+		t.Fatalf("Command with PTY failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "vt100 132x50" {
+		t.Errorf("Expected PTY vt100 132x50, got %q", got)
+	}
+
+	output, err = client.Command(`echo "${DINGO_TEST_PTY:-none}"`).Output()
+	if err != nil {
+		t.Fatalf("Command without PTY failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "none" {
+		t.Errorf("Expected no PTY by default, got %q", got)
+	}
+}
+
+func TestRemoteScript_WithPTY_Script(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+	script := "echo \"one $DINGO_TEST_PTY\"\necho two\n"
+
+	// The test server echoes input like a terminal, a script sent over stdin would show up in the output
+	output, err := client.Script(script).WithPTY(nil).Output()
+	if err != nil {
+		t.Fatalf("Script with PTY failed: %v", err)
+	}
+	if got := string(output); got != "one 80x40\ntwo\n" {
+		t.Errorf("Expected only the script output, got %q", got)
+	}
+
+	file := filepath.Join(t.TempDir(), "script.sh")
+	os.WriteFile(file, []byte(script), 0644)
+	output, err = client.ScriptFile(file).WithPTY(nil).Output()
+	if err != nil {
+		t.Fatalf("ScriptFile with PTY failed: %v", err)
+	}
+	if got := string(output); got != "one 80x40\ntwo\n" {
+		t.Errorf("Expected only the script output, got %q", got)
+	}
+}
+
+func TestRemoteScript_WithPTY_DefaultConfig(t *testing.T) {
+	rs := createTestRemoteScript(CommandLine, "top -b", "", false)
+
+	if result := rs.WithPTY(nil); result != rs {
+		t.Error("WithPTY should return the same instance for chaining")
+	}
+	if rs.terminalConfig != DefaultTerminalConfig {
+		t.Error("Expected DefaultTerminalConfig when config is nil")
+	}
+}
@@ -0,0 +1,152 @@
+package dingo
+
+import (
+	"io"
+)
+
+// DefaultEscapeChar is the escape character recognised at the start of a line, as in OpenSSH
+const DefaultEscapeChar = '~'
+
+// EscapeHandler is called with the character typed after the escape character and reports whether it handled it
+type EscapeHandler func(command byte) bool
+
+// EscapeReader filters interactive input for OpenSSH-style escape sequences such as "~." typed at the start of a line
+type EscapeReader struct {
+	r       io.Reader
+	escape  byte
+	handler EscapeHandler
+
+	buf         []byte // Input read from r but not processed yet
+	out         []byte // Filtered input ready to be returned
+	lineStart   bool
+	afterEscape bool
+}
+
+/*
+* Creates a reader that passes input through unchanged except for escape sequences at the start of a line
+* "~~" sends a single "~", sequences the handler does not handle are passed through as typed
+* Inputs: r (io.Reader) - raw terminal input, escape (byte) - escape character, e.g. DefaultEscapeChar, handler (EscapeHandler) - called for each escape sequence
+* Outputs: *EscapeReader to use as the shell's stdin
+ */
+func NewEscapeReader(r io.Reader, escape byte, handler EscapeHandler) *EscapeReader {
+	return &EscapeReader{
+		r:         r,
+		escape:    escape,
+		handler:   handler,
+		lineStart: true,
+	}
+}
+
+/*
+* Reads filtered input, running the handler for escape sequences found on the way
+* Inputs: p ([]byte) - destination buffer
+* Outputs: int containing bytes read, error from the underlying reader
+ */
+func (er *EscapeReader) Read(p []byte) (int, error) {
+	for len(er.out) == 0 {
+		if len(er.buf) == 0 {
+			if err := er.fill(); err != nil {
+				return 0, err
+			}
+		}
+		for len(er.buf) > 0 {
+			b := er.buf[0]
+			er.buf = er.buf[1:]
+			er.filter(b)
+		}
+	}
+
+	n := copy(p, er.out)
+	er.out = er.out[n:]
+	return n, nil
+}
+
+/*
+* Reads a line typed on a raw terminal, echoing it and handling backspace, Ctrl+U and Ctrl+C
+* Meant for escape handlers that prompt for input, e.g. the "~C" command line
+* Inputs: echo (io.Writer) - terminal output used to echo the typed characters
+* Outputs: string containing the line without its terminator, "" if aborted with Ctrl+C, error from the underlying reader
+ */
+func (er *EscapeReader) ReadLine(echo io.Writer) (string, error) {
+	var line []byte
+	for {
+		if len(er.buf) == 0 {
+			if err := er.fill(); err != nil {
+				return "", err
+			}
+		}
+		b := er.buf[0]
+		er.buf = er.buf[1:]
+
+		switch b {
+		case '\r', '\n':
+			io.WriteString(echo, "\r\n")
+			return string(line), nil
+		case 3: // Ctrl+C
+			io.WriteString(echo, "\r\n")
+			return "", nil
+		case 21: // Ctrl+U
+			for range line {
+				io.WriteString(echo, "\b \b")
+			}
+			line = line[:0]
+		case 8, 127: // Backspace
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				io.WriteString(echo, "\b \b")
+			}
+		default:
+			if b >= ' ' {
+				line = append(line, b)
+				echo.Write([]byte{b})
+			}
+		}
+	}
+}
+
+/*
+* Internal helper that reads more input from the underlying reader
+* Inputs: none
+* Outputs: error from the underlying reader if nothing was read
+ */
+func (er *EscapeReader) fill() error {
+	chunk := make([]byte, 256)
+	n, err := er.r.Read(chunk)
+	er.buf = append(er.buf, chunk[:n]...)
+	if n > 0 {
+		return nil
+	}
+	if err == nil {
+		err = io.ErrNoProgress
+	}
+	return err
+}
+
+/*
+* Internal helper that runs one input byte through the escape state machine
+* Inputs: b (byte) - input byte
+* Outputs: none (appends pass-through input to er.out)
+ */
+func (er *EscapeReader) filter(b byte) {
+	if er.afterEscape {
+		er.afterEscape = false
+		switch {
+		case b == er.escape:
+			er.out = append(er.out, b)
+			er.lineStart = false
+		case er.handler != nil && er.handler(b):
+			er.lineStart = true
+		default:
+			er.out = append(er.out, er.escape, b)
+			er.lineStart = b == '\r' || b == '\n'
+		}
+		return
+	}
+
+	if er.lineStart && b == er.escape {
+		er.afterEscape = true
+		return
+	}
+	er.out = append(er.out, b)
+	er.lineStart = b == '\r' || b == '\n'
+}
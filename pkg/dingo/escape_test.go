@@ -0,0 +1,92 @@
+package dingo
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// byteReader returns its input one byte per Read, like keystrokes on a raw terminal
+type byteReader struct {
+	data []byte
+}
+
+func (r *byteReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
+
+func TestEscapeReader_Sequences(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		output   string
+		commands string
+	}{
+		{"plain input", "ls -l\r", "ls -l\r", ""},
+		{"handled at start", "~.", "", "."},
+		{"handled after newline", "echo\r~?ls\r", "echo\rls\r", "?"},
+		{"not at line start", "a~.\r", "a~.\r", ""},
+		{"double escape", "~~.\r", "~.\r", ""},
+		{"unhandled passes through", "~x\r", "~x\r", "x"},
+		{"after handled sequence", "~?~.", "", "?."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, reader := range []io.Reader{strings.NewReader(tt.input), &byteReader{data: []byte(tt.input)}} {
+				var commands string
+				er := NewEscapeReader(reader, DefaultEscapeChar, func(command byte) bool {
+					commands += string(command)
+					return command == '.' || command == '?'
+				})
+
+				output, err := io.ReadAll(er)
+				if err != nil {
+					t.Fatalf("Read failed: %v", err)
+				}
+				if string(output) != tt.output || commands != tt.commands {
+					t.Errorf("Expected output %q and commands %q, got %q and %q", tt.output, tt.commands, output, commands)
+				}
+			}
+		})
+	}
+}
+
+func TestEscapeReader_ReadLine(t *testing.T) {
+	var echo bytes.Buffer
+	var line string
+	var er *EscapeReader
+	er = NewEscapeReader(strings.NewReader("~C-L 80\x7f1\r\x15ls\r"), DefaultEscapeChar, func(command byte) bool {
+		line, _ = er.ReadLine(&echo)
+		return command == 'C'
+	})
+
+	output, err := io.ReadAll(er)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if line != "-L 81" {
+		t.Errorf("Unexpected command line %q", line)
+	}
+	if string(output) != "\x15ls\r" {
+		t.Errorf("Command line leaked into the shell input: %q", output)
+	}
+	if echo.String() != "-L 80\b \b1\r\n" {
+		t.Errorf("Unexpected echo %q", echo.String())
+	}
+}
+
+func TestEscapeReader_ReadLineAbort(t *testing.T) {
+	var echo bytes.Buffer
+	er := NewEscapeReader(strings.NewReader("-L 80\x03"), DefaultEscapeChar, nil)
+
+	if line, err := er.ReadLine(&echo); err != nil || line != "" {
+		t.Errorf("Expected aborted line, got %q, %v", line, err)
+	}
+}
@@ -0,0 +1,253 @@
+package dingo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Expect errors, wrapped in an *ExpectError carrying the unmatched output
+var (
+	ErrExpectTimeout = errors.New("expect: timed out waiting for a match")
+	ErrExpectEOF     = errors.New("expect: shell exited before a match")
+)
+
+// ExpectError describes a failed Expect call together with the output that did not match
+type ExpectError struct {
+	Err    error
+	Output string
+}
+
+/*
+* Returns the expect failure with the unmatched output appended
+* Inputs: none
+* Outputs: string containing the error message
+ */
+func (e *ExpectError) Error() string {
+	return fmt.Sprintf("%v, output: %q", e.Err, e.Output)
+}
+
+/*
+* Returns the underlying sentinel error so callers can use errors.Is
+* Inputs: none
+* Outputs: error - ErrExpectTimeout or ErrExpectEOF
+ */
+func (e *ExpectError) Unwrap() error {
+	return e.Err
+}
+
+// ExpectPattern is a literal or regular expression waited for by Expect
+type ExpectPattern struct {
+	re *regexp.Regexp
+}
+
+/*
+* Creates a pattern matching the exact text
+* Inputs: text (string) - literal text to wait for
+* Outputs: ExpectPattern matching the text
+ */
+func ExpectLiteral(text string) ExpectPattern {
+	return ExpectPattern{re: regexp.MustCompile(regexp.QuoteMeta(text))}
+}
+
+/*
+* Creates a pattern matching a regular expression, submatches are returned in ExpectMatch.Groups
+* Inputs: re (*regexp.Regexp) - compiled expression to wait for
+* Outputs: ExpectPattern matching the expression
+ */
+func ExpectRegexp(re *regexp.Regexp) ExpectPattern {
+	return ExpectPattern{re: re}
+}
+
+// ExpectMatch describes the output consumed by a successful Expect call
+type ExpectMatch struct {
+	Index  int      // Position of the matching pattern in the Expect arguments
+	Before string   // Output preceding the match
+	Match  string   // Matched text
+	Groups []string // Regular expression submatches, Groups[0] is the whole match
+}
+
+// expecter implements the Expecter interface on top of a running Shell
+type expecter struct {
+	config ExpectConfig
+	stdin  *io.PipeWriter
+	done   chan struct{}
+	err    error // Result of the shell, valid once done is closed
+
+	mu      sync.Mutex
+	buf     []byte
+	changed chan struct{} // Closed and replaced whenever buf grows or the shell exits
+	eof     bool
+}
+
+/*
+* Starts a shell in the background with its streams attached to an Expecter
+* Output on stdout and stderr is matched together, so a PTY shell sees it in terminal order
+* Inputs: shell (Shell) - shell to drive, command (string) - program to run instead of the login shell, "" for the shell, opts (...ExpectOption) - expect options
+* Outputs: Expecter interface for sending input and waiting for output
+ */
+func StartExpect(shell Shell, command string, opts ...ExpectOption) Expecter {
+	config := *DefaultExpectConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	stdinReader, stdinWriter := io.Pipe()
+	e := &expecter{
+		config:  config,
+		stdin:   stdinWriter,
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+
+	shell.SetStdio(stdinReader, e, e)
+	go func() {
+		err := shell.Start(command)
+
+		// Fail pending and future sends instead of blocking on a reader that is gone
+		stdinReader.CloseWithError(ErrExpectEOF)
+
+		e.mu.Lock()
+		e.err = err
+		e.eof = true
+		close(e.changed)
+		e.mu.Unlock()
+		close(e.done)
+	}()
+
+	return e
+}
+
+/*
+* Sends raw input to the shell, e.g. a single key for a menu
+* Inputs: input (string) - data to send
+* Outputs: error if the shell has exited
+ */
+func (e *expecter) Send(input string) error {
+	_, err := io.WriteString(e.stdin, input)
+	return err
+}
+
+/*
+* Sends a line of input to the shell
+* Inputs: line (string) - data to send, a newline is appended
+* Outputs: error if the shell has exited
+ */
+func (e *expecter) SendLine(line string) error {
+	return e.Send(line + "\n")
+}
+
+/*
+* Waits for any of the patterns using the configured timeout
+* Inputs: patterns (...ExpectPattern) - patterns to wait for
+* Outputs: *ExpectMatch for the earliest match, *ExpectError wrapping ErrExpectTimeout or ErrExpectEOF otherwise
+ */
+func (e *expecter) Expect(patterns ...ExpectPattern) (*ExpectMatch, error) {
+	return e.ExpectTimeout(e.config.Timeout, patterns...)
+}
+
+/*
+* Waits for any of the patterns and consumes the output up to the end of the match
+* When several patterns match, the one matching earliest in the output wins, ties go to the first pattern
+* Inputs: timeout (time.Duration) - maximum wait, patterns (...ExpectPattern) - patterns to wait for
+* Outputs: *ExpectMatch for the earliest match, *ExpectError wrapping ErrExpectTimeout or ErrExpectEOF otherwise
+ */
+func (e *expecter) ExpectTimeout(timeout time.Duration, patterns ...ExpectPattern) (*ExpectMatch, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		e.mu.Lock()
+		if match := e.match(patterns); match != nil {
+			e.mu.Unlock()
+			return match, nil
+		}
+		if e.eof {
+			output := string(e.buf)
+			e.mu.Unlock()
+			return nil, &ExpectError{Err: ErrExpectEOF, Output: output}
+		}
+		changed := e.changed
+		e.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			e.mu.Lock()
+			output := string(e.buf)
+			e.mu.Unlock()
+			return nil, &ExpectError{Err: ErrExpectTimeout, Output: output}
+		}
+	}
+}
+
+/*
+* Closes the shell input and waits for the shell to exit
+* On a PTY the end of input is not always seen by the remote program, so send "exit" or similar first
+* Inputs: none
+* Outputs: error returned by the shell, e.g. *ssh.ExitError for a non-zero exit status
+ */
+func (e *expecter) Close() error {
+	e.stdin.Close()
+	<-e.done
+	return e.err
+}
+
+/*
+* Receives shell output, making it available for matching
+* Inputs: p ([]byte) - output written by the SSH session
+* Outputs: int containing len(p), nil error
+ */
+func (e *expecter) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	e.buf = append(e.buf, p...)
+	if e.config.MaxBuffer > 0 && len(e.buf) > e.config.MaxBuffer {
+		e.buf = append([]byte(nil), e.buf[len(e.buf)-e.config.MaxBuffer:]...)
+	}
+	// stdout and stderr are written from separate goroutines, the log is written under the lock as well
+	if e.config.Log != nil {
+		e.config.Log.Write(p)
+	}
+	close(e.changed)
+	e.changed = make(chan struct{})
+	e.mu.Unlock()
+	return len(p), nil
+}
+
+/*
+* Internal helper that finds the earliest match in the buffer and consumes it, the caller must hold e.mu
+* Inputs: patterns ([]ExpectPattern) - patterns to look for
+* Outputs: *ExpectMatch or nil if no pattern matches yet
+ */
+func (e *expecter) match(patterns []ExpectPattern) *ExpectMatch {
+	index := -1
+	var loc []int
+	for i, pattern := range patterns {
+		l := pattern.re.FindSubmatchIndex(e.buf)
+		if l != nil && (loc == nil || l[0] < loc[0]) {
+			index, loc = i, l
+		}
+	}
+	if loc == nil {
+		return nil
+	}
+
+	match := &ExpectMatch{
+		Index:  index,
+		Before: string(e.buf[:loc[0]]),
+		Match:  string(e.buf[loc[0]:loc[1]]),
+	}
+	for i := 0; i < len(loc); i += 2 {
+		if loc[i] < 0 {
+			match.Groups = append(match.Groups, "")
+			continue
+		}
+		match.Groups = append(match.Groups, string(e.buf[loc[i]:loc[i+1]]))
+	}
+
+	e.buf = append([]byte(nil), e.buf[loc[1]:]...)
+	return match
+}
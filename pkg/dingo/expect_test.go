@@ -0,0 +1,145 @@
+package dingo
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestExpect_PromptAndBranch(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	var log bytes.Buffer
+	e := StartExpect(client.Shell(), `printf 'Continue? [y/n] '; read answer; if [ "$answer" = y ]; then echo installed; else echo aborted; exit 3; fi`, WithExpectLog(&log))
+
+	if _, err := e.Expect(ExpectLiteral("[y/n] ")); err != nil {
+		t.Fatalf("Prompt not seen: %v", err)
+	}
+	if err := e.SendLine("y"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	match, err := e.Expect(ExpectLiteral("aborted"), ExpectRegexp(regexp.MustCompile(`(install)ed`)))
+	if err != nil {
+		t.Fatalf("Result not seen: %v", err)
+	}
+	if match.Index != 1 || match.Match != "installed" || len(match.Groups) != 2 || match.Groups[1] != "install" {
+		t.Errorf("Unexpected match: %+v", match)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Errorf("Shell failed: %v", err)
+	}
+	if log.String() != "Continue? [y/n] installed\n" {
+		t.Errorf("Unexpected log: %q", log.String())
+	}
+}
+
+func TestExpect_ExitStatus(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	e := StartExpect(client.Shell(), `read answer; exit 3`)
+	e.SendLine("n")
+
+	var exitErr *ssh.ExitError
+	if err := e.Close(); !errors.As(err, &exitErr) || exitErr.ExitStatus() != 3 {
+		t.Errorf("Expected exit status 3, got %v", err)
+	}
+}
+
+func TestExpect_EarliestMatchWins(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	e := StartExpect(client.Shell(), `printf 'login: then password:'`)
+	match, err := e.Expect(ExpectLiteral("password:"), ExpectLiteral("login:"))
+	if err != nil {
+		t.Fatalf("Expect failed: %v", err)
+	}
+	if match.Index != 1 || match.Before != "" {
+		t.Errorf("Expected the earlier login prompt, got %+v", match)
+	}
+
+	// The rest of the output stays available for the next call
+	match, err = e.Expect(ExpectLiteral("password:"))
+	if err != nil || match.Before != " then " {
+		t.Errorf("Unexpected second match %+v, %v", match, err)
+	}
+	e.Close()
+}
+
+func TestExpect_Timeout(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	e := StartExpect(client.Shell(), `echo waiting; read answer`, WithExpectTimeout(100*time.Millisecond))
+	_, err := e.Expect(ExpectLiteral("never"))
+
+	var expectErr *ExpectError
+	if !errors.Is(err, ErrExpectTimeout) || !errors.As(err, &expectErr) {
+		t.Fatalf("Expected timeout, got %v", err)
+	}
+
+	// Unmatched output is kept, so a later call can still match it
+	if _, err := e.ExpectTimeout(time.Second, ExpectLiteral("waiting")); err != nil {
+		t.Errorf("Buffered output lost after timeout: %v (%q)", err, expectErr.Output)
+	}
+
+	e.SendLine("")
+	if err := e.Close(); err != nil {
+		t.Errorf("Shell failed: %v", err)
+	}
+}
+
+func TestExpect_EOF(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	e := StartExpect(client.Shell(), `echo bye`)
+	_, err := e.Expect(ExpectLiteral("never"))
+
+	var expectErr *ExpectError
+	if !errors.Is(err, ErrExpectEOF) || !errors.As(err, &expectErr) || expectErr.Output != "bye\n" {
+		t.Fatalf("Expected EOF with output, got %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Errorf("Shell failed: %v", err)
+	}
+	if err := e.SendLine("late"); err == nil {
+		t.Error("Expected send to an exited shell to fail")
+	}
+}
+
+func TestExpecter_MaxBuffer(t *testing.T) {
+	e := &expecter{config: ExpectConfig{MaxBuffer: 4}, changed: make(chan struct{})}
+	e.Write([]byte("abcdef"))
+	e.Write([]byte("gh"))
+
+	if string(e.buf) != "efgh" {
+		t.Errorf("Expected buffer to keep the newest output, got %q", e.buf)
+	}
+}
+
+func TestExpecter_ConcurrentLog(t *testing.T) {
+	var log bytes.Buffer
+	e := &expecter{config: ExpectConfig{Log: &log}, changed: make(chan struct{})}
+
+	// stdout and stderr write from their own goroutines, run with -race to check the log
+	var wg sync.WaitGroup
+	for _, output := range []string{"out\n", "err\n"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				e.Write([]byte(output))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if log.Len() != 800 || log.String() != string(e.buf) {
+		t.Errorf("Expected the log to match the 800 bytes of output, got %d bytes", log.Len())
+	}
+}
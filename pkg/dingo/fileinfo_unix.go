@@ -0,0 +1,27 @@
+//go:build !windows
+
+package dingo
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+/*
+* Internal helper that reads the access time and owner of a local file, following symbolic links
+* Inputs: name (string) - file path, info (os.FileInfo) - information already read, unused on this platform
+* Outputs: fileAttributes of the file, error if the file cannot be examined
+ */
+func localFileAttributes(name string, info os.FileInfo) (fileAttributes, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(name, &stat); err != nil {
+		return fileAttributes{}, &os.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return fileAttributes{
+		atime: time.Unix(stat.Atim.Unix()),
+		uid:   int(stat.Uid),
+		gid:   int(stat.Gid),
+	}, nil
+}
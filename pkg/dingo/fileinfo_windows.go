@@ -0,0 +1,22 @@
+//go:build windows
+
+package dingo
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+/*
+* Internal helper that reads the access time of a local file, Windows files have no uid and gid
+* Inputs: name (string) - file path, unused on this platform, info (os.FileInfo) - information already read
+* Outputs: fileAttributes of the file with uid and gid set to -1, error never
+ */
+func localFileAttributes(name string, info os.FileInfo) (fileAttributes, error) {
+	attrs := fileAttributes{atime: info.ModTime(), uid: -1, gid: -1}
+	if data, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		attrs.atime = time.Unix(0, data.LastAccessTime.Nanoseconds())
+	}
+	return attrs, nil
+}
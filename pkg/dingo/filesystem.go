@@ -1,8 +1,10 @@
 package dingo
 
 import (
+	"errors"
 	"io"
 	"os"
+	"time"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
@@ -36,7 +38,8 @@ func (rfs *remoteFileSystem) ReadFile(name string) ([]byte, error) {
 }
 
 /*
-* Writes byte data to a remote file with specified permissions, creating or truncating as needed
+* Writes byte data to a remote file, creating or truncating as needed
+* Like os.WriteFile, a new file gets perm (before the server's umask) and an existing file keeps its permissions
 * Inputs: name (string) - remote file path, data ([]byte) - content to write, perm (os.FileMode) - file permissions
 * Outputs: error if file cannot be written or SFTP error exists, nil on success
  */
@@ -45,7 +48,7 @@ func (rfs *remoteFileSystem) WriteFile(name string, data []byte, perm os.FileMod
 		return rfs.err
 	}
 
-	f, err := rfs.sftp.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	f, err := createRemoteFile(rfs.sftp, name, perm)
 	if err != nil {
 		return err
 	}
@@ -56,55 +59,89 @@ func (rfs *remoteFileSystem) WriteFile(name string, data []byte, perm os.FileMod
 }
 
 /*
-* Transfers a file from local filesystem to remote server via SFTP
-* Inputs: localPath (string) - path to local source file, remotePath (string) - destination path on remote server
-* Outputs: error if transfer fails due to file access or network issues, nil on successful transfer
+* Writes byte data to a remote file without ever leaving it partially written
+* The data goes to a temporary file in the same directory, which is flushed with fsync when the server supports
+* fsync@openssh.com and then renamed over the target with posix-rename@openssh.com, on failure it is removed
+* Inputs: name (string) - remote file path, data ([]byte) - content to write, perm (os.FileMode) - file permissions
+* Outputs: error if file cannot be written or replaced or SFTP error exists, nil on success
  */
-func (rfs *remoteFileSystem) Upload(localPath, remotePath string) error {
+func (rfs *remoteFileSystem) WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
 	if rfs.err != nil {
 		return rfs.err
 	}
 
-	localFile, err := os.Open(localPath)
+	fs := rfs.transferFS()
+	tmp := atomicTempName(name)
+	f, err := fs.Create(tmp, perm)
 	if err != nil {
 		return err
 	}
-	defer localFile.Close()
-
-	remoteFile, err := rfs.sftp.OpenFile(remotePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+	_, err = f.Write(data)
+	if err == nil {
+		err = fs.Sync(f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = fs.Rename(tmp, name)
+	}
 	if err != nil {
-		return err
+		fs.Remove(tmp)
 	}
-	defer remoteFile.Close()
-
-	_, err = io.Copy(remoteFile, localFile)
 	return err
 }
 
 /*
-* Transfers a file from remote server to local filesystem via SFTP
-* Inputs: remotePath (string) - path to remote source file, localPath (string) - destination path on local filesystem
+* Transfers a file from local filesystem to remote server via SFTP, a new remote file gets the local file's permissions
+* Inputs: localPath (string) - path to local source file, remotePath (string) - destination path on remote server, opts (...TransferOption) - progress, resume, verification and preserve options
 * Outputs: error if transfer fails due to file access or network issues, nil on successful transfer
  */
-func (rfs *remoteFileSystem) Download(remotePath, localPath string) error {
+func (rfs *remoteFileSystem) Upload(localPath, remotePath string, opts ...TransferOption) error {
 	if rfs.err != nil {
 		return rfs.err
 	}
+	return newTreeTransfer(localTransferFS{}, rfs.transferFS(), rfs.config, opts).runFile(localPath, remotePath)
+}
 
-	remoteFile, err := rfs.sftp.Open(remotePath)
-	if err != nil {
-		return err
+/*
+* Transfers a file from remote server to local filesystem via SFTP, a new local file gets the remote file's permissions
+* Inputs: remotePath (string) - path to remote source file, localPath (string) - destination path on local filesystem, opts (...TransferOption) - progress, resume, verification and preserve options
+* Outputs: error if transfer fails due to file access or network issues, nil on successful transfer
+ */
+func (rfs *remoteFileSystem) Download(remotePath, localPath string, opts ...TransferOption) error {
+	if rfs.err != nil {
+		return rfs.err
 	}
-	defer remoteFile.Close()
+	return newTreeTransfer(rfs.transferFS(), localTransferFS{}, rfs.config, opts).runFile(remotePath, localPath)
+}
+
+/*
+* Internal helper that creates or truncates a remote file, like os.OpenFile with O_CREATE and O_TRUNC
+* SFTP servers create files with their own default mode, so a new file gets perm limited by the server's umask,
+* which shows in that default mode. Existing files keep their mode and owner
+* Inputs: client (*sftp.Client) - SFTP session, name (string) - remote file path, perm (os.FileMode) - permissions of a new file
+* Outputs: *sftp.File opened for writing, error if the file cannot be created or its mode set
+ */
+func createRemoteFile(client *sftp.Client, name string, perm os.FileMode) (*sftp.File, error) {
+	_, err := client.Stat(name)
+	created := errors.Is(err, os.ErrNotExist)
 
-	localFile, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	f, err := client.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil || !created {
+		return f, err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		// Servers create files as 0666 minus their umask, the execute bits follow the read bits
+		mode := info.Mode().Perm()
+		err = f.Chmod(perm.Perm() & (mode | (mode&0444)>>2))
+	}
 	if err != nil {
-		return err
+		f.Close()
+		return nil, err
 	}
-	defer localFile.Close()
-
-	_, err = io.Copy(localFile, remoteFile)
-	return err
+	return f, nil
 }
 
 /*
@@ -227,6 +264,66 @@ func (rfs *remoteFileSystem) Rename(oldname, newname string) error {
 	return rfs.sftp.Rename(oldname, newname)
 }
 
+/*
+* Changes the access and modification times of a remote file or directory
+* Inputs: path (string) - remote path, atime (time.Time) - access time, mtime (time.Time) - modification time
+* Outputs: error if the times cannot be changed or SFTP error exists, nil on success
+ */
+func (rfs *remoteFileSystem) Chtimes(path string, atime, mtime time.Time) error {
+	if rfs.err != nil {
+		return rfs.err
+	}
+	return rfs.sftp.Chtimes(path, atime, mtime)
+}
+
+/*
+* Creates a symbolic link on the remote server
+* Inputs: oldname (string) - link target, stored as given, newname (string) - remote path of the link
+* Outputs: error if the link cannot be created or SFTP error exists, nil on success
+ */
+func (rfs *remoteFileSystem) Symlink(oldname, newname string) error {
+	if rfs.err != nil {
+		return rfs.err
+	}
+	return rfs.sftp.Symlink(oldname, newname)
+}
+
+/*
+* Reads the target of a remote symbolic link
+* Inputs: path (string) - remote path of the link
+* Outputs: string containing the link target, error if the path is not a link or SFTP error exists
+ */
+func (rfs *remoteFileSystem) ReadLink(path string) (string, error) {
+	if rfs.err != nil {
+		return "", rfs.err
+	}
+	return rfs.sftp.ReadLink(path)
+}
+
+/*
+* Creates a hard link on the remote server, the server must support the hardlink@openssh.com extension
+* Inputs: oldname (string) - existing remote file, newname (string) - remote path of the new link
+* Outputs: error if the link cannot be created or SFTP error exists, nil on success
+ */
+func (rfs *remoteFileSystem) Link(oldname, newname string) error {
+	if rfs.err != nil {
+		return rfs.err
+	}
+	return rfs.sftp.Link(oldname, newname)
+}
+
+/*
+* Changes the size of a remote file, cutting it or extending it with zeros
+* Inputs: path (string) - remote file path, size (int64) - new size
+* Outputs: error if the file cannot be resized or SFTP error exists, nil on success
+ */
+func (rfs *remoteFileSystem) Truncate(path string, size int64) error {
+	if rfs.err != nil {
+		return rfs.err
+	}
+	return rfs.sftp.Truncate(path, size)
+}
+
 /*
 * Closes the SFTP session and releases associated resources
 * Inputs: none
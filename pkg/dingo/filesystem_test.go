@@ -3,7 +3,9 @@ package dingo
 import (
 	"errors"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/pkg/sftp"
 )
@@ -67,6 +69,133 @@ func TestRemoteFileSystem_WriteFile_WithError(t *testing.T) {
 	}
 }
 
+func TestRemoteFileSystem_WriteFileAtomic_WithError(t *testing.T) {
+	rfs := createTestRemoteFileSystem(nil, true)
+
+	err := rfs.WriteFileAtomic("/test/file.txt", []byte("test data"), 0644)
+	if err == nil || err.Error() != "test SFTP error" {
+		t.Errorf("Expected 'test SFTP error', got: %v", err)
+	}
+}
+
+func TestRemoteFileSystem_WriteFileAtomic(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "service.conf")
+	if err := os.WriteFile(name, []byte("old config"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := fs.WriteFileAtomic(name, []byte("new config"), 0600); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+	if data, _ := os.ReadFile(name); string(data) != "new config" {
+		t.Errorf("Expected replaced contents, got %q", data)
+	}
+	if info, err := os.Stat(name); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v, %v", info, err)
+	}
+
+	// Renaming over a non-empty directory fails, the temporary file must not be left behind
+	target := filepath.Join(dir, "busy")
+	os.MkdirAll(filepath.Join(target, "child"), 0755)
+	if err := fs.WriteFileAtomic(target, []byte("data"), 0644); err == nil {
+		t.Error("Expected error replacing a non-empty directory")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("Expected only service.conf and busy in %s, got %d entries", dir, len(entries))
+	}
+}
+
+func TestRemoteFileSystem_WriteFile_Permissions(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	dir := t.TempDir()
+
+	created := filepath.Join(dir, "secret")
+	if err := fs.WriteFile(created, []byte("token"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	script := filepath.Join(dir, "run.sh")
+	if err := fs.WriteFile(script, []byte("#!/bin/sh"), 0755); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	// Existing files keep their mode, like os.WriteFile
+	config := filepath.Join(dir, "app.conf")
+	os.WriteFile(config, []byte("old"), 0640)
+	if err := fs.WriteFile(config, []byte("new"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if data, _ := os.ReadFile(config); string(data) != "new" {
+		t.Errorf("Expected the existing file to be rewritten, got %q", data)
+	}
+
+	for name, mode := range map[string]os.FileMode{created: 0600, script: 0755, config: 0640} {
+		if info, err := os.Stat(name); err != nil || info.Mode().Perm() != mode {
+			t.Errorf("%s: expected mode %v, got %v, %v", name, mode, info, err)
+		}
+	}
+}
+
+func TestRemoteFileSystem_LinksAndAttributes(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	dir := t.TempDir()
+	name := filepath.Join(dir, "data.bin")
+	if err := fs.WriteFile(name, []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if err := fs.Truncate(name, 4); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if data, _ := os.ReadFile(name); string(data) != "0123" {
+		t.Errorf("Expected truncated contents, got %q", data)
+	}
+
+	atime := time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+	mtime := time.Date(2024, 6, 2, 9, 30, 0, 0, time.UTC)
+	if err := fs.Chtimes(name, atime, mtime); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if info, _ := fs.Stat(name); !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected mtime %v, got %v", mtime, info.ModTime())
+	}
+
+	link := filepath.Join(dir, "current")
+	if err := fs.Symlink("data.bin", link); err != nil {
+		t.Fatalf("Symlink failed: %v", err)
+	}
+	if target, err := fs.ReadLink(link); err != nil || target != "data.bin" {
+		t.Errorf("ReadLink = %q, %v, expected data.bin", target, err)
+	}
+
+	hard := filepath.Join(dir, "hard.bin")
+	if err := fs.Link(name, hard); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	a, _ := os.Stat(name)
+	b, _ := os.Stat(hard)
+	if !os.SameFile(a, b) {
+		t.Error("Expected the hard link to refer to the same file")
+	}
+}
+
+func TestRemoteFileSystem_LinksAndAttributes_WithError(t *testing.T) {
+	rfs := createTestRemoteFileSystem(nil, true)
+	errs := map[string]error{
+		"Chtimes":  rfs.Chtimes("file", time.Now(), time.Now()),
+		"Truncate": rfs.Truncate("file", 0),
+		"Symlink":  rfs.Symlink("target", "link"),
+		"Link":     rfs.Link("file", "link"),
+	}
+	_, errs["ReadLink"] = rfs.ReadLink("link")
+	for method, err := range errs {
+		if err == nil || err.Error() != "test SFTP error" {
+			t.Errorf("%s: expected 'test SFTP error', got: %v", method, err)
+		}
+	}
+}
+
 func TestRemoteFileSystem_WriteFile_NilSFTP(t *testing.T) {
 	rfs := createTestRemoteFileSystem(nil, false)
 
@@ -443,6 +572,7 @@ func TestRemoteFileSystem_Interface_Compliance(t *testing.T) {
 	// These will panic but we're just testing interface compliance
 	rfs.ReadFile("test")
 	rfs.WriteFile("test", []byte("data"), 0644)
+	rfs.WriteFileAtomic("test", []byte("data"), 0644)
 	rfs.Upload("local", "remote")
 	rfs.Download("remote", "local")
 	rfs.Mkdir("dir")
@@ -454,6 +584,11 @@ func TestRemoteFileSystem_Interface_Compliance(t *testing.T) {
 	rfs.ReadDir("dir")
 	rfs.Chmod("file", 0755)
 	rfs.Chown("file", 1000, 1000)
+	rfs.Chtimes("file", time.Now(), time.Now())
+	rfs.Truncate("file", 0)
+	rfs.Symlink("file", "link")
+	rfs.ReadLink("link")
+	rfs.Link("file", "link")
 	rfs.Rename("old", "new")
 	rfs.Close()
 }
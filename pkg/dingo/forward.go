@@ -0,0 +1,295 @@
+package dingo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ErrForwardRejected is returned when the server refuses to listen for a remote forward
+var ErrForwardRejected = errors.New("remote forwarding rejected by the server (check AllowTcpForwarding and GatewayPorts in sshd_config, and that the port is free)")
+
+// forwarder implements the Forward interface by proxying every accepted connection to a dialed target
+type forwarder struct {
+	listener net.Listener
+	target   string
+	dial     func(conn net.Conn) (net.Conn, error)
+	config   *ForwardConfig
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{} // Both sides of every proxied connection, closed with the forward
+	active int
+	err    error
+	closed bool
+}
+
+/*
+* Forwards connections to a local address through the SSH connection to an address reachable from the remote host, like ssh -L
+* As in OpenSSH, an address containing a "/" is a Unix socket path, e.g. /var/run/docker.sock on the remote host
+* Inputs: localAddr (string) - local host:port or socket path to listen on, remoteAddr (string) - host:port or socket path dialed from the remote host, opts (...ForwardOption) - forwarding options
+* Outputs: Forward interface for the listening forward, error if the local address cannot be listened on
+ */
+func (c *client) ForwardLocal(localAddr, remoteAddr string, opts ...ForwardOption) (Forward, error) {
+	listener, err := listenLocal(localAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	network := forwardNetwork(remoteAddr)
+	return newForwarder(listener, remoteAddr, func(net.Conn) (net.Conn, error) {
+		return c.sshClient.Dial(network, remoteAddr)
+	}, newForwardConfig(opts)), nil
+}
+
+/*
+* Forwards connections to an address on the remote host back through the SSH connection to a local address, like ssh -R
+* The remote host must resolve to an IP address, an empty host listens on all remote interfaces
+* As in OpenSSH, an address containing a "/" is a Unix socket path
+* Inputs: remoteAddr (string) - host:port or socket path the server listens on, port 0 lets the server choose, localAddr (string) - host:port or socket path dialed locally, opts (...ForwardOption) - forwarding options
+* Outputs: Forward interface whose Addr is the remote listening address, ErrForwardRejected if the server refuses the forward
+ */
+func (c *client) ForwardRemote(remoteAddr, localAddr string, opts ...ForwardOption) (Forward, error) {
+	network := forwardNetwork(remoteAddr)
+	if host, port, err := net.SplitHostPort(remoteAddr); network == "tcp" && err == nil && host == "" {
+		remoteAddr = net.JoinHostPort("0.0.0.0", port)
+	}
+
+	listener, err := c.sshClient.Listen(network, remoteAddr)
+	if err != nil {
+		// golang.org/x/crypto/ssh does not export an error for denied tcpip-forward and streamlocal-forward requests
+		if strings.Contains(err.Error(), "request denied by peer") {
+			return nil, fmt.Errorf("%w: %s", ErrForwardRejected, remoteAddr)
+		}
+		return nil, fmt.Errorf("failed to listen on remote %s: %w", remoteAddr, err)
+	}
+
+	localNetwork := forwardNetwork(localAddr)
+	return newForwarder(listener, localAddr, func(net.Conn) (net.Conn, error) {
+		return net.Dial(localNetwork, localAddr)
+	}, newForwardConfig(opts)), nil
+}
+
+/*
+* Internal helper that returns the network of a forwarding address
+* Inputs: addr (string) - host:port or Unix socket path
+* Outputs: string containing "unix" if the address contains a "/", "tcp" otherwise
+ */
+func forwardNetwork(addr string) string {
+	if strings.Contains(addr, "/") {
+		return "unix"
+	}
+	return "tcp"
+}
+
+/*
+* Internal helper that listens on a local forwarding address, Unix sockets are only accessible by the current user
+* Inputs: addr (string) - host:port or Unix socket path
+* Outputs: net.Listener for the address, error if it cannot be listened on
+ */
+func listenLocal(addr string) (net.Listener, error) {
+	var listener net.Listener
+	var err error
+	if forwardNetwork(addr) == "unix" {
+		listener, err = ListenUnix(addr)
+	} else {
+		listener, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return listener, nil
+}
+
+/*
+* Internal helper that builds a forward configuration from the defaults and the given options
+* Inputs: opts ([]ForwardOption) - forward options to apply
+* Outputs: *ForwardConfig containing the resulting configuration
+ */
+func newForwardConfig(opts []ForwardOption) *ForwardConfig {
+	config := *DefaultForwardConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return &config
+}
+
+/*
+* Internal helper that starts proxying the connections accepted on a listener
+* Inputs: listener (net.Listener) - accepting side, target (string) - target description for errors, dial (func) - opens the connection to the target for an accepted connection, config (*ForwardConfig) - forwarding configuration
+* Outputs: *forwarder serving in the background until closed
+ */
+func newForwarder(listener net.Listener, target string, dial func(conn net.Conn) (net.Conn, error), config *ForwardConfig) *forwarder {
+	f := &forwarder{
+		listener: listener,
+		target:   target,
+		dial:     dial,
+		config:   config,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	go f.serve()
+	return f
+}
+
+/*
+* Returns the address the forward listens on, useful when listening on port 0
+* Inputs: none
+* Outputs: net.Addr of the listener
+ */
+func (f *forwarder) Addr() net.Addr {
+	return f.listener.Addr()
+}
+
+/*
+* Returns the number of connections currently being forwarded
+* Inputs: none
+* Outputs: int containing the connection count
+ */
+func (f *forwarder) Connections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
+
+/*
+* Returns the error that stopped the forward from accepting connections
+* Inputs: none
+* Outputs: error from the listener, nil while the forward is running or after Close
+ */
+func (f *forwarder) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+/*
+* Stops listening and closes every forwarded connection
+* Inputs: none
+* Outputs: error if the listener fails to close
+ */
+func (f *forwarder) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	for conn := range f.conns {
+		conn.Close()
+	}
+	f.mu.Unlock()
+
+	return f.listener.Close()
+}
+
+/*
+* Internal helper that accepts connections until the listener is closed
+* Inputs: none
+* Outputs: none
+ */
+func (f *forwarder) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			f.mu.Lock()
+			if !f.closed {
+				f.err = err
+			}
+			f.mu.Unlock()
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+/*
+* Internal helper that connects an accepted connection to the target and copies data both ways
+* Inputs: conn (net.Conn) - accepted connection
+* Outputs: none (dial failures are passed to the error handler)
+ */
+func (f *forwarder) handle(conn net.Conn) {
+	if !f.track(conn, 1) {
+		return
+	}
+	defer f.untrack(conn, 1)
+
+	target, err := f.dial(conn)
+	if err != nil {
+		f.report(fmt.Errorf("forward from %s to %s: %w", conn.RemoteAddr(), f.target, err))
+		return
+	}
+	if !f.track(target, 0) {
+		return
+	}
+	defer f.untrack(target, 0)
+
+	proxyConns(conn, target)
+}
+
+/*
+* Internal helper that registers a connection so Close can interrupt it, the connection is closed if the forward is closed
+* Inputs: conn (net.Conn) - connection to register, count (int) - added to the active connection count
+* Outputs: bool reporting whether the connection was registered
+ */
+func (f *forwarder) track(conn net.Conn, count int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		conn.Close()
+		return false
+	}
+	f.conns[conn] = struct{}{}
+	f.active += count
+	return true
+}
+
+/*
+* Internal helper that closes and unregisters a connection
+* Inputs: conn (net.Conn) - connection to unregister, count (int) - subtracted from the active connection count
+* Outputs: none
+ */
+func (f *forwarder) untrack(conn net.Conn, count int) {
+	conn.Close()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.conns, conn)
+	f.active -= count
+}
+
+/*
+* Internal helper that passes a connection error to the configured handler
+* Inputs: err (error) - connection error
+* Outputs: none
+ */
+func (f *forwarder) report(err error) {
+	if f.config.ErrorHandler != nil {
+		f.config.ErrorHandler(err)
+	}
+}
+
+/*
+* Internal helper that copies data between two connections until both directions are done
+* Each direction is half-closed when its source ends so request/response protocols finish cleanly
+* Inputs: a (net.Conn) - first connection, b (net.Conn) - second connection
+* Outputs: none
+ */
+func proxyConns(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+		done <- struct{}{}
+	}
+	go pipe(a, b)
+	go pipe(b, a)
+	<-done
+	<-done
+}
@@ -0,0 +1,507 @@
+package dingo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// serveGlobalRequests handles the tcpip-forward and streamlocal-forward requests of a test server connection by listening locally
+func serveGlobalRequests(conn *ssh.ServerConn, reqs <-chan *ssh.Request) {
+	var mu sync.Mutex
+	listeners := make(map[string]net.Listener)
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}()
+
+	for req := range reqs {
+		var network, key string
+		var tcpPayload struct {
+			Addr string
+			Port uint32
+		}
+		var unixPayload struct{ SocketPath string }
+		switch req.Type {
+		case "tcpip-forward", "cancel-tcpip-forward":
+			if ssh.Unmarshal(req.Payload, &tcpPayload) == nil {
+				network, key = "tcp", net.JoinHostPort(tcpPayload.Addr, strconv.Itoa(int(tcpPayload.Port)))
+			}
+		case "streamlocal-forward@openssh.com", "cancel-streamlocal-forward@openssh.com":
+			if ssh.Unmarshal(req.Payload, &unixPayload) == nil {
+				network, key = "unix", unixPayload.SocketPath
+			}
+		}
+		if network == "" {
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(req.Type, "cancel-") {
+			mu.Lock()
+			listener, ok := listeners[key]
+			delete(listeners, key)
+			mu.Unlock()
+			if ok {
+				listener.Close()
+			}
+			req.Reply(ok, nil)
+			continue
+		}
+
+		listener, err := net.Listen(network, key)
+		if err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+
+		var reply []byte
+		openForwarded := func(local net.Conn) (ssh.Channel, <-chan *ssh.Request, error) {
+			return conn.OpenChannel("forwarded-streamlocal@openssh.com", ssh.Marshal(struct {
+				SocketPath string
+				Reserved   string
+			}{key, ""}))
+		}
+		if network == "tcp" {
+			port := uint32(listener.Addr().(*net.TCPAddr).Port)
+			key = net.JoinHostPort(tcpPayload.Addr, strconv.Itoa(int(port)))
+			reply = ssh.Marshal(struct{ Port uint32 }{port})
+			openForwarded = func(local net.Conn) (ssh.Channel, <-chan *ssh.Request, error) {
+				origin := local.RemoteAddr().(*net.TCPAddr)
+				return conn.OpenChannel("forwarded-tcpip", ssh.Marshal(struct {
+					Addr       string
+					Port       uint32
+					OriginAddr string
+					OriginPort uint32
+				}{tcpPayload.Addr, port, origin.IP.String(), uint32(origin.Port)}))
+			}
+		}
+		mu.Lock()
+		listeners[key] = listener
+		mu.Unlock()
+		req.Reply(true, reply)
+
+		go func() {
+			for {
+				local, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				channel, requests, err := openForwarded(local)
+				if err != nil {
+					local.Close()
+					continue
+				}
+				go ssh.DiscardRequests(requests)
+				go proxyChannel(channel, local)
+			}
+		}()
+	}
+}
+
+// serveDirectChannel connects a direct-tcpip or direct-streamlocal channel of the test server to the requested address
+func serveDirectChannel(newChannel ssh.NewChannel) {
+	var network, addr string
+	if newChannel.ChannelType() == "direct-tcpip" {
+		var payload struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, "invalid payload")
+			return
+		}
+		network, addr = "tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+	} else {
+		var payload struct {
+			SocketPath string
+			Reserved0  string
+			Reserved1  uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, "invalid payload")
+			return
+		}
+		network, addr = "unix", payload.SocketPath
+	}
+
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	proxyChannel(channel, conn)
+}
+
+// proxyChannel copies data between a test server channel and a connection, half-closing each direction
+func proxyChannel(channel ssh.Channel, conn net.Conn) {
+	defer channel.Close()
+	defer conn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(conn, channel)
+		if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+		close(done)
+	}()
+	io.Copy(channel, conn)
+	channel.CloseWrite()
+	<-done
+}
+
+/*
+* Test helper that starts a TCP server answering every line with "echo: " and the line
+* Inputs: t (*testing.T) - test context
+* Outputs: string containing the server address
+ */
+func startLineEchoServer(t *testing.T) string {
+	t.Helper()
+	return startLineEchoServerOn(t, "tcp", "127.0.0.1:0")
+}
+
+/*
+* Test helper that starts a line echo server on the given network and address
+* Inputs: t (*testing.T) - test context, network (string) - "tcp" or "unix", addr (string) - address or socket path
+* Outputs: string containing the server address
+ */
+func startLineEchoServerOn(t *testing.T, network, addr string) string {
+	t.Helper()
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					fmt.Fprintf(conn, "echo: %s\n", scanner.Text())
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+/*
+* Test helper that sends a line over a new connection and checks the echoed reply
+* Inputs: t (*testing.T) - test context, network (string) - "tcp" or "unix", addr (string) - address to dial, line (string) - line to send
+* Outputs: none
+ */
+func checkLineEcho(t *testing.T, network, addr, line string) {
+	t.Helper()
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintln(conn, line)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if reply, err := bufio.NewReader(conn).ReadString('\n'); err != nil || reply != "echo: "+line+"\n" {
+		t.Errorf("Unexpected reply %q, %v", reply, err)
+	}
+}
+
+func TestForwardLocal(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+	target := startLineEchoServer(t)
+
+	forward, err := client.ForwardLocal("127.0.0.1:0", target)
+	if err != nil {
+		t.Fatalf("ForwardLocal failed: %v", err)
+	}
+	defer forward.Close()
+
+	// Several connections are forwarded at the same time
+	var conns []net.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("tcp", forward.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for i, conn := range conns {
+		fmt.Fprintf(conn, "hello %d\n", i)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != fmt.Sprintf("echo: hello %d\n", i) {
+			t.Errorf("Unexpected reply %q, %v", line, err)
+		}
+	}
+	if n := forward.Connections(); n != 3 {
+		t.Errorf("Expected 3 active connections, got %d", n)
+	}
+
+	conns[0].Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for forward.Connections() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 active connections, got %d", forward.Connections())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Close stops listening and ends the remaining connections
+	if err := forward.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := io.ReadAll(conns[1]); err != nil {
+		t.Errorf("Expected the forwarded connection to end, got %v", err)
+	}
+	if conn, err := net.Dial("tcp", forward.Addr().String()); err == nil {
+		conn.Close()
+		t.Error("Forward still accepting after Close")
+	}
+	if err := forward.Err(); err != nil {
+		t.Errorf("Expected no error after Close, got %v", err)
+	}
+}
+
+func TestForwardLocal_DialError(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	// Reserve a port and free it again so nothing listens on it
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	target := listener.Addr().String()
+	listener.Close()
+
+	var mu sync.Mutex
+	var reported []error
+	forward, err := client.ForwardLocal("127.0.0.1:0", target, WithForwardErrorHandler(func(err error) {
+		mu.Lock()
+		reported = append(reported, err)
+		mu.Unlock()
+	}))
+	if err != nil {
+		t.Fatalf("ForwardLocal failed: %v", err)
+	}
+	defer forward.Close()
+
+	conn, err := net.Dial("tcp", forward.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+	conn.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	var openErr *ssh.OpenChannelError
+	if len(reported) != 1 || !errors.As(reported[0], &openErr) || !strings.Contains(reported[0].Error(), target) {
+		t.Errorf("Unexpected reported errors: %v", reported)
+	}
+}
+
+func TestForwardRemote(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+	target := startLineEchoServer(t)
+
+	forward, err := client.ForwardRemote("127.0.0.1:0", target)
+	if err != nil {
+		t.Fatalf("ForwardRemote failed: %v", err)
+	}
+	addr := forward.Addr().String()
+	if strings.HasSuffix(addr, ":0") {
+		t.Fatalf("Expected the server to choose a port, got %s", addr)
+	}
+
+	// The test server listens on this host, so the remote address can be dialed directly
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprintln(conn, "callback")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != "echo: callback\n" {
+		t.Errorf("Unexpected reply %q, %v", line, err)
+	}
+
+	// Close cancels the remote listener and ends the forwarded connection
+	if err := forward.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Errorf("Expected the forwarded connection to end, got %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("Remote listener still open after Close")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForwardRemote_Rejected(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	// The server cannot listen on a port that is already taken
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer busy.Close()
+
+	if _, err := client.ForwardRemote(busy.Addr().String(), "127.0.0.1:80"); !errors.Is(err, ErrForwardRejected) {
+		t.Errorf("Expected ErrForwardRejected, got %v", err)
+	}
+}
+
+func TestForwardLocal_ListenError(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	if _, err := client.ForwardLocal("256.0.0.1:0", "127.0.0.1:80"); err == nil {
+		t.Error("Expected listen error")
+	}
+}
+
+func TestForwardLocal_UnixSocket(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+	dir := t.TempDir()
+	remoteSocket := startLineEchoServerOn(t, "unix", filepath.Join(dir, "docker.sock"))
+	localSocket := filepath.Join(dir, "local.sock")
+
+	forward, err := client.ForwardLocal(localSocket, remoteSocket)
+	if err != nil {
+		t.Fatalf("ForwardLocal failed: %v", err)
+	}
+	defer forward.Close()
+
+	if info, err := os.Stat(localSocket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a private local socket, got %v, %v", info, err)
+	}
+	checkLineEcho(t, "unix", localSocket, "docker ps")
+
+	// Sockets can also be forwarded to TCP ports and dialed directly
+	tcpForward, err := client.ForwardLocal("127.0.0.1:0", remoteSocket)
+	if err != nil {
+		t.Fatalf("ForwardLocal failed: %v", err)
+	}
+	defer tcpForward.Close()
+	checkLineEcho(t, "tcp", tcpForward.Addr().String(), "over tcp")
+
+	conn, err := client.DialContext(context.Background(), "unix", remoteSocket)
+	if err != nil {
+		t.Fatalf("DialContext failed: %v", err)
+	}
+	conn.Close()
+}
+
+func TestForwardRemote_UnixSocket(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+	target := startLineEchoServer(t)
+	remoteSocket := filepath.Join(t.TempDir(), "collector.sock")
+
+	forward, err := client.ForwardRemote(remoteSocket, target)
+	if err != nil {
+		t.Fatalf("ForwardRemote failed: %v", err)
+	}
+	if forward.Addr().String() != remoteSocket {
+		t.Errorf("Unexpected address %s", forward.Addr())
+	}
+	checkLineEcho(t, "unix", remoteSocket, "results")
+
+	if err := forward.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if conn, err := net.Dial("unix", remoteSocket); err == nil {
+		conn.Close()
+		t.Error("Remote socket still accepting after Close")
+	}
+
+	// A socket path that cannot be listened on is rejected by the server
+	if _, err := client.ForwardRemote("/nonexistent/dir/x.sock", target); !errors.Is(err, ErrForwardRejected) {
+		t.Errorf("Expected ErrForwardRejected, got %v", err)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.sock")
+
+	listener, err := ListenUnix(path)
+	if err != nil {
+		t.Fatalf("ListenUnix failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 || info.Mode()&os.ModeSocket == 0 {
+		t.Errorf("Expected a private socket, got %v, %v", info, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected only the socket in the directory, found %d entries", len(entries))
+	}
+	if listener.Addr().String() != path {
+		t.Errorf("Unexpected address %s", listener.Addr())
+	}
+
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Write([]byte("ok"))
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	if data, _ := io.ReadAll(conn); string(data) != "ok" {
+		t.Errorf("Expected the listener to accept, got %q", data)
+	}
+	conn.Close()
+
+	// An existing path is never replaced
+	if _, err := ListenUnix(path); err == nil {
+		t.Error("Expected error for an existing path")
+	}
+	listener.Close()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected Close to remove the socket, got %v", err)
+	}
+}
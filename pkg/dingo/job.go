@@ -0,0 +1,267 @@
+package dingo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Detached job errors
+var (
+	ErrInvalidJobID   = errors.New("invalid job ID: use letters, digits, '-' and '_'")
+	ErrJobNotFound    = errors.New("job not found")
+	ErrJobRunning     = errors.New("job is still running")
+	ErrJobWaitTimeout = errors.New("timed out waiting for job")
+)
+
+// jobNotFoundStatus is the exit status job commands use when the job directory does not exist
+const jobNotFoundStatus = 3
+
+var jobIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// jobLauncher starts the job wrapper in its own session so it survives the SSH connection.
+// The wrapper records the command's output, exit code and finish time in the job directory.
+const jobLauncher = `set -e
+mkdir -p %[1]s/%[2]s
+d=$(cd %[1]s/%[2]s && pwd)
+printf '%%s\n' %[3]s > "$d/command"
+date +%%s > "$d/started"
+setsid=
+if command -v setsid >/dev/null 2>&1; then setsid=setsid; fi
+$setsid nohup sh -c '(cd "$2" && exec sh "$1/command") >"$1/stdout" 2>"$1/stderr" </dev/null; code=$?; date +%%s >"$1/finished"; echo $code >"$1/exit_code.tmp" && mv "$1/exit_code.tmp" "$1/exit_code"' dingo-job "$d" %[4]s >/dev/null 2>&1 </dev/null &
+echo $! > "$d/pid"
+`
+
+// remoteJob implements the Job interface using a job directory on the remote host
+type remoteJob struct {
+	client *client
+	id     string
+	config *JobConfig
+	err    error
+}
+
+/*
+* Starts a command detached from the SSH connection, its output and exit code are kept in a per-job directory
+* Inputs: command (string) - shell command to run, opts (...JobOption) - directory and polling options
+* Outputs: Job interface for the started job, error if the job cannot be launched
+ */
+func (c *client) StartJob(command string, opts ...JobOption) (Job, error) {
+	token := make([]byte, 3)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	id := time.Now().UTC().Format("20060102-150405") + "-" + hex.EncodeToString(token)
+	job := &remoteJob{client: c, id: id, config: newJobConfig(opts)}
+
+	workDir := job.config.WorkDir
+	if workDir == "" {
+		workDir = "."
+	}
+	launcher := fmt.Sprintf(jobLauncher, shellQuote(job.config.Dir), id, shellQuote(command), shellQuote(workDir))
+	if output, err := c.Script(launcher, WithInterpreter("sh")).SmartOutput(); err != nil {
+		return nil, fmt.Errorf("failed to start job: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return job, nil
+}
+
+/*
+* Returns a handle for an existing job, e.g. one started by an earlier connection
+* Inputs: id (string) - job ID returned by StartJob, opts (...JobOption) - must use the same directory as StartJob
+* Outputs: Job interface for the job, its methods report ErrJobNotFound if it does not exist
+ */
+func (c *client) Job(id string, opts ...JobOption) Job {
+	job := &remoteJob{client: c, id: id, config: newJobConfig(opts)}
+	if !jobIDPattern.MatchString(id) {
+		job.err = fmt.Errorf("%w: %q", ErrInvalidJobID, id)
+	}
+	return job
+}
+
+/*
+* Lists the IDs of the jobs in the job directory, oldest first
+* Inputs: opts (...JobOption) - directory options
+* Outputs: []string containing the job IDs, error if the directory cannot be read
+ */
+func (c *client) ListJobs(opts ...JobOption) ([]string, error) {
+	config := newJobConfig(opts)
+
+	command := "cd " + shellQuote(config.Dir) + " 2>/dev/null || exit 0; for d in *; do [ -f \"$d/pid\" ] && echo \"$d\"; done; true"
+	output, err := c.Command(command).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+/*
+* Internal helper that builds a job configuration from the defaults and the given options
+* Inputs: opts ([]JobOption) - job options to apply
+* Outputs: *JobConfig containing the resulting configuration
+ */
+func newJobConfig(opts []JobOption) *JobConfig {
+	config := *DefaultJobConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return &config
+}
+
+/*
+* Returns the job ID
+* Inputs: none
+* Outputs: string containing the ID
+ */
+func (j *remoteJob) ID() string {
+	return j.id
+}
+
+/*
+* Reads the current state of the job from its directory
+* Inputs: none
+* Outputs: *JobStatus describing the job, ErrJobNotFound if the job does not exist
+ */
+func (j *remoteJob) Status() (*JobStatus, error) {
+	output, err := j.run(`pid=$(cat pid 2>/dev/null); echo "pid=$pid"; echo "exit=$(cat exit_code 2>/dev/null)"; ` +
+		`echo "started=$(cat started 2>/dev/null)"; echo "finished=$(cat finished 2>/dev/null)"; ` +
+		`[ -f cancelled ] && echo cancelled=1; [ -n "$pid" ] && kill -0 "$pid" 2>/dev/null && echo alive=1; true`)
+	if err != nil {
+		return nil, err
+	}
+	return parseJobStatus(j.id, string(output)), nil
+}
+
+/*
+* Returns the last lines of the job's stdout or stderr
+* Inputs: stream (JobStream) - JobStdout or JobStderr, lines (int) - number of lines from the end, 0 for the whole log
+* Outputs: []byte containing the log text, ErrJobNotFound if the job does not exist
+ */
+func (j *remoteJob) Logs(stream JobStream, lines int) ([]byte, error) {
+	if stream != JobStdout && stream != JobStderr {
+		return nil, fmt.Errorf("unknown job stream %q", stream)
+	}
+	if lines > 0 {
+		return j.run(fmt.Sprintf("tail -n %d %s", lines, stream))
+	}
+	return j.run("cat " + string(stream))
+}
+
+/*
+* Polls the job until it is no longer running
+* Inputs: timeout (time.Duration) - maximum wait, 0 to wait indefinitely
+* Outputs: *JobStatus of the finished job, ErrJobWaitTimeout with the last status if the timeout expires
+ */
+func (j *remoteJob) Wait(timeout time.Duration) (*JobStatus, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := j.Status()
+		if err != nil {
+			return nil, err
+		}
+		if status.State != JobRunning {
+			return status, nil
+		}
+		if timeout > 0 && time.Now().Add(j.config.PollInterval).After(deadline) {
+			return status, ErrJobWaitTimeout
+		}
+		time.Sleep(j.config.PollInterval)
+	}
+}
+
+/*
+* Sends SIGTERM to the job's process group, a job that has already finished is left unchanged
+* Inputs: none
+* Outputs: error if the job does not exist or the command fails
+ */
+func (j *remoteJob) Cancel() error {
+	_, err := j.run(`[ -f exit_code ] && exit 0; pid=$(cat pid) || exit 1; touch cancelled; ` +
+		`kill -TERM "-$pid" 2>/dev/null || kill -TERM "$pid" 2>/dev/null; true`)
+	return err
+}
+
+/*
+* Deletes the job directory with its logs, running jobs must be cancelled first
+* Inputs: none
+* Outputs: error if the job is still running, does not exist or cannot be removed
+ */
+func (j *remoteJob) Remove() error {
+	status, err := j.Status()
+	if err != nil {
+		return err
+	}
+	if status.State == JobRunning {
+		return fmt.Errorf("%w: %s", ErrJobRunning, j.id)
+	}
+
+	command := "rm -rf " + shellQuote(j.config.Dir) + "/" + j.id
+	if output, err := j.client.Command(command).SmartOutput(); err != nil {
+		return fmt.Errorf("failed to remove job %s: %w: %s", j.id, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+/*
+* Internal helper that runs a command inside the job directory
+* Inputs: command (string) - single-line shell command
+* Outputs: []byte containing stdout, ErrJobNotFound if the directory is missing, other errors with the remote message
+ */
+func (j *remoteJob) run(command string) ([]byte, error) {
+	if j.err != nil {
+		return nil, j.err
+	}
+
+	dir := shellQuote(j.config.Dir) + "/" + j.id
+	output, err := j.client.Command(fmt.Sprintf("cd %s 2>/dev/null || exit %d; %s", dir, jobNotFoundStatus, command)).SmartOutput()
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitStatus() == jobNotFoundStatus {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, j.id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("job %s: %w: %s", j.id, err, strings.TrimSpace(string(output)))
+	}
+	return output, nil
+}
+
+/*
+* Internal helper that parses the key=value lines printed by the status command
+* Inputs: id (string) - job ID, output (string) - command output
+* Outputs: *JobStatus describing the job
+ */
+func parseJobStatus(id, output string) *JobStatus {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			values[key] = strings.TrimSpace(value)
+		}
+	}
+
+	status := &JobStatus{ID: id}
+	status.PID, _ = strconv.Atoi(values["pid"])
+	if seconds, err := strconv.ParseInt(values["started"], 10, 64); err == nil {
+		status.StartedAt = time.Unix(seconds, 0)
+	}
+	if seconds, err := strconv.ParseInt(values["finished"], 10, 64); err == nil {
+		status.FinishedAt = time.Unix(seconds, 0)
+	}
+
+	exitCode, err := strconv.Atoi(values["exit"])
+	switch {
+	case err == nil:
+		status.State = JobFinished
+		status.ExitCode = exitCode
+	case values["alive"] == "1":
+		status.State = JobRunning
+	case values["cancelled"] == "1":
+		status.State = JobCancelled
+	default:
+		status.State = JobLost
+	}
+	return status
+}
@@ -0,0 +1,139 @@
+package dingo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJob_RunToCompletion(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+	dir := t.TempDir()
+	workDir := t.TempDir()
+
+	job, err := client.StartJob("pwd; echo out; echo err >&2; exit 4", WithJobDir(dir), WithJobWorkDir(workDir), WithJobPollInterval(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("StartJob failed: %v", err)
+	}
+
+	status, err := job.Wait(5 * time.Second)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if status.State != JobFinished || status.ExitCode != 4 || status.PID == 0 || status.StartedAt.IsZero() || status.FinishedAt.IsZero() {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	stdout, err := job.Logs(JobStdout, 0)
+	if err != nil || string(stdout) != workDir+"\nout\n" {
+		t.Errorf("Unexpected stdout %q, %v", stdout, err)
+	}
+	stderr, err := job.Logs(JobStderr, 1)
+	if err != nil || string(stderr) != "err\n" {
+		t.Errorf("Unexpected stderr %q, %v", stderr, err)
+	}
+
+	if err := job.Remove(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, job.ID())); !os.IsNotExist(err) {
+		t.Errorf("Job directory not removed: %v", err)
+	}
+}
+
+func TestJob_SurvivesConnection(t *testing.T) {
+	dir := t.TempDir()
+	sshClient := createExecSSHServer(t)
+
+	job, err := newClient(sshClient, nil).StartJob("sleep 1; echo done", WithJobDir(dir))
+	if err != nil {
+		t.Fatalf("StartJob failed: %v", err)
+	}
+	sshClient.Close()
+
+	// A later connection picks the job up by its ID
+	later := newClient(createExecSSHServer(t), nil)
+	ids, err := later.ListJobs(WithJobDir(dir))
+	if err != nil || len(ids) != 1 || ids[0] != job.ID() {
+		t.Fatalf("Unexpected job list %v, %v", ids, err)
+	}
+
+	reattached := later.Job(job.ID(), WithJobDir(dir), WithJobPollInterval(50*time.Millisecond))
+	if status, err := reattached.Status(); err != nil || status.State != JobRunning {
+		t.Fatalf("Expected running job, got %+v, %v", status, err)
+	}
+	if err := reattached.Remove(); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Expected ErrJobRunning, got %v", err)
+	}
+
+	status, err := reattached.Wait(5 * time.Second)
+	if err != nil || status.State != JobFinished || status.ExitCode != 0 {
+		t.Fatalf("Unexpected final status %+v, %v", status, err)
+	}
+	if stdout, _ := reattached.Logs(JobStdout, 0); string(stdout) != "done\n" {
+		t.Errorf("Unexpected stdout %q", stdout)
+	}
+}
+
+func TestJob_Cancel(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+	dir := t.TempDir()
+
+	job, err := client.StartJob("sleep 30", WithJobDir(dir), WithJobPollInterval(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("StartJob failed: %v", err)
+	}
+
+	if _, err := job.Wait(100 * time.Millisecond); !errors.Is(err, ErrJobWaitTimeout) {
+		t.Errorf("Expected ErrJobWaitTimeout, got %v", err)
+	}
+	if err := job.Cancel(); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+
+	status, err := job.Wait(5 * time.Second)
+	if err != nil || status.State != JobCancelled {
+		t.Fatalf("Expected cancelled job, got %+v, %v", status, err)
+	}
+
+	// The whole process group is terminated, not just the wrapper shell
+	deadline := time.Now().Add(2 * time.Second)
+	for client.Command(fmt.Sprintf("kill -0 -%d 2>/dev/null", status.PID)).Run() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Job processes still running after Cancel")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestJob_NotFound(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	if _, err := client.Job("20260101-000000-abcdef", WithJobDir(t.TempDir())).Status(); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+	if _, err := client.Job("../etc").Logs(JobStdout, 0); !errors.Is(err, ErrInvalidJobID) {
+		t.Errorf("Expected ErrInvalidJobID, got %v", err)
+	}
+}
+
+func TestParseJobStatus(t *testing.T) {
+	tests := []struct {
+		output string
+		state  JobState
+	}{
+		{"pid=42\nexit=0\nstarted=1\nfinished=2\n", JobFinished},
+		{"pid=42\nexit=\nstarted=1\nfinished=\nalive=1\n", JobRunning},
+		{"pid=42\nexit=\nstarted=1\nfinished=\ncancelled=1\n", JobCancelled},
+		{"pid=42\nexit=\nstarted=1\nfinished=\n", JobLost},
+	}
+
+	for _, tt := range tests {
+		if status := parseJobStatus("id", tt.output); status.State != tt.state || status.PID != 42 {
+			t.Errorf("Output %q: expected %s, got %+v", tt.output, tt.state, status)
+		}
+	}
+}
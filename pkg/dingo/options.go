@@ -1,6 +1,11 @@
 package dingo
 
-// SFTP Option functions - simplified for single-threaded operation
+import (
+	"io"
+	"time"
+)
+
+// SFTP Option functions
 
 /*
 * Creates an SFTP option that sets the maximum packet size for file transfer operations
@@ -24,17 +29,64 @@ func WithFstat(enabled bool) SftpOption {
 	}
 }
 
-// Common SFTP option presets for single-threaded operation
+/*
+* Creates an SFTP option that sets how many requests may be in flight for a single file operation
+* Inputs: n (int) - maximum concurrent requests per file
+* Outputs: SftpOption function that applies the request limit
+ */
+func WithConcurrentRequests(n int) SftpOption {
+	return func(config *SftpConfig) {
+		config.ConcurrentRequests = n
+	}
+}
+
+/*
+* Creates an SFTP option that copies large files as several ranges at the same time
+* Inputs: n (int) - number of ranges copied at once, 1 to copy files sequentially
+* Outputs: SftpOption function that applies the parallelism
+ */
+func WithParallelChunks(n int) SftpOption {
+	return func(config *SftpConfig) {
+		config.ParallelChunks = n
+	}
+}
+
+/*
+* Creates an SFTP option that sets the size of the ranges large files are split into
+* Inputs: size (int64) - range size in bytes, files smaller than two ranges are copied sequentially
+* Outputs: SftpOption function that applies the range size
+ */
+func WithChunkSize(size int64) SftpOption {
+	return func(config *SftpConfig) {
+		config.ChunkSize = size
+	}
+}
+
+/*
+* Creates an SFTP option that sets how many files of a directory transfer are copied at the same time
+* Inputs: n (int) - number of files copied at once
+* Outputs: SftpOption function that applies the worker count
+ */
+func WithWorkers(n int) SftpOption {
+	return func(config *SftpConfig) {
+		config.Workers = n
+	}
+}
+
+// Common SFTP option presets
 
 /*
-* Returns a set of SFTP options optimized for maximum transfer speed in single-threaded mode
+* Returns a set of SFTP options optimized for maximum transfer speed on fast links
 * Inputs: none
 * Outputs: []SftpOption slice containing speed-optimized configuration options
  */
 func FastSftpOptions() []SftpOption {
 	return []SftpOption{
-		WithMaxPacket(65536), // Larger packets for speed
-		WithFstat(false),     // Skip fstat for speed
+		WithMaxPacket(65536),        // Larger packets for speed, supported by OpenSSH
+		WithFstat(false),            // Skip fstat for speed
+		WithConcurrentRequests(128), // Keep long links busy
+		WithParallelChunks(8),       // Copy large files in 8 ranges at once
+		WithWorkers(8),              // Copy 8 files of a directory at once
 	}
 }
 
@@ -45,8 +97,10 @@ func FastSftpOptions() []SftpOption {
  */
 func SafeSftpOptions() []SftpOption {
 	return []SftpOption{
-		WithMaxPacket(32768), // Smaller packets for reliability
-		WithFstat(true),      // Use fstat for safety
+		WithMaxPacket(32768),  // Smaller packets for reliability
+		WithFstat(true),       // Use fstat for safety
+		WithParallelChunks(1), // Write files front to back
+		WithWorkers(1),        // One file at a time
 	}
 }
 
@@ -61,3 +115,397 @@ func DefaultSftpOptions() []SftpOption {
 		WithFstat(false),     // Skip fstat for moderate speed
 	}
 }
+
+// Transfer Option functions
+
+/*
+* Creates a transfer option that selects how directory transfers handle symbolic links
+* Inputs: policy (SymlinkPolicy) - SymlinkFollow, SymlinkPreserve or SymlinkSkip
+* Outputs: TransferOption function that applies the symlink policy
+ */
+func WithSymlinks(policy SymlinkPolicy) TransferOption {
+	return func(config *TransferConfig) {
+		config.Symlinks = policy
+	}
+}
+
+/*
+* Creates a transfer option that only transfers files matching one of the glob patterns
+* Patterns without a "/" match file names, patterns with a "/" match paths relative to the transferred directory
+* Inputs: patterns (...string) - path.Match patterns such as "*.safetensors" or "logs/*.log"
+* Outputs: TransferOption function that adds the include patterns
+ */
+func WithInclude(patterns ...string) TransferOption {
+	return func(config *TransferConfig) {
+		config.Include = append(config.Include, patterns...)
+	}
+}
+
+/*
+* Creates a transfer option that leaves out files and directories matching one of the glob patterns
+* Patterns are matched like WithInclude, an excluded directory is not descended into
+* Inputs: patterns (...string) - path.Match patterns such as "*.tmp" or ".git"
+* Outputs: TransferOption function that adds the exclude patterns
+ */
+func WithExclude(patterns ...string) TransferOption {
+	return func(config *TransferConfig) {
+		config.Exclude = append(config.Exclude, patterns...)
+	}
+}
+
+/*
+* Creates a transfer option that continues interrupted transfers
+* Files are written to "<name>.dingo-partial" front to back one packet at a time and renamed once complete, so an
+* interrupted copy never leaves gaps and the next run continues the partial file at its size. This is slower than
+* the parallel ranges and concurrent writes used otherwise
+* Any other existing destination may come from an interrupted copy with gaps, so it is hashed with SHA-256 and kept,
+* continued or skipped as complete only when it matches the start of the source
+* Inputs: enabled (bool) - whether to resume partial destination files
+* Outputs: TransferOption function that applies the resume setting
+ */
+func WithResume(enabled bool) TransferOption {
+	return func(config *TransferConfig) {
+		config.Resume = enabled
+	}
+}
+
+/*
+* Creates a transfer option that also checks partial files before a transfer is resumed
+* The first bytes of source and partial file are hashed with SHA-256, files that differ are copied again from the start
+* Remote prefixes are hashed on the host with head and sha256sum when available, instead of being read back over SFTP
+* Inputs: enabled (bool) - whether to verify resumed files, only used together with WithResume
+* Outputs: TransferOption function that applies the verification setting
+ */
+func WithVerifyResume(enabled bool) TransferOption {
+	return func(config *TransferConfig) {
+		config.VerifyResume = enabled
+	}
+}
+
+/*
+* Creates a transfer option that keeps the source metadata, like scp -p
+* Upload and Download apply the mode, access and modification times, directory transfers always keep modes
+* and modification times and also get the access times
+* Inputs: enabled (bool) - whether to preserve metadata
+* Outputs: TransferOption function that applies the preserve setting
+ */
+func WithPreserve(enabled bool) TransferOption {
+	return func(config *TransferConfig) {
+		config.Preserve = enabled
+	}
+}
+
+/*
+* Creates a transfer option that also gives the destination files the source uid and gid, implies WithPreserve
+* The ids are copied as numbers, users with the same name can have different ids on both sides
+* Local files on Windows have no owner ids and keep the default owner
+* Inputs: enabled (bool) - whether to preserve ownership
+* Outputs: TransferOption function that applies the ownership setting
+ */
+func WithPreserveOwner(enabled bool) TransferOption {
+	return func(config *TransferConfig) {
+		config.PreserveOwner = enabled
+	}
+}
+
+/*
+* Creates a transfer option that never leaves a partially written destination file
+* Each file is written to a temporary file next to it, flushed with fsync when the server supports fsync@openssh.com
+* and renamed over the destination with posix-rename@openssh.com, a failed copy removes the temporary file
+* The replaced file's mode and owner are not kept, with WithResume only complete files are skipped
+* Inputs: enabled (bool) - whether to write files atomically
+* Outputs: TransferOption function that applies the atomic setting
+ */
+func WithAtomic(enabled bool) TransferOption {
+	return func(config *TransferConfig) {
+		config.Atomic = enabled
+	}
+}
+
+/*
+* Creates a transfer option that compares the checksums of source and destination after each file is copied
+* Remote files are hashed by the matching *sum command on the host, or streamed back over SFTP when it is missing
+* A mismatch stops the transfer with a *ChecksumMismatchError and leaves the destination file in place
+* Inputs: enabled (bool) - whether to verify copied files
+* Outputs: TransferOption function that applies the verification setting
+ */
+func WithVerify(enabled bool) TransferOption {
+	return func(config *TransferConfig) {
+		config.Verify = enabled
+	}
+}
+
+/*
+* Creates a transfer option that selects the hash used by WithVerify
+* Inputs: algo (ChecksumAlgorithm) - ChecksumSHA256 (default), ChecksumSHA512, ChecksumSHA1 or ChecksumMD5
+* Outputs: TransferOption function that sets the checksum algorithm
+ */
+func WithChecksum(algo ChecksumAlgorithm) TransferOption {
+	return func(config *TransferConfig) {
+		config.Checksum = algo
+	}
+}
+
+/*
+* Creates a transfer option that reports the progress of the transfer
+* The callback runs on the transferring goroutine, it should return quickly
+* Inputs: callback (func(TransferProgress)) - receives the bytes and files done, the totals, the current file and the rate
+* Outputs: TransferOption function that sets the progress callback
+ */
+func WithProgress(callback func(progress TransferProgress)) TransferOption {
+	return func(config *TransferConfig) {
+		config.Progress = callback
+	}
+}
+
+/*
+* Creates a transfer option that sets the minimum time between progress calls
+* Inputs: interval (time.Duration) - minimum time between calls, 0 reports every copied buffer
+* Outputs: TransferOption function that applies the interval
+ */
+func WithProgressInterval(interval time.Duration) TransferOption {
+	return func(config *TransferConfig) {
+		config.ProgressInterval = interval
+	}
+}
+
+// Script Option functions
+
+/*
+* Creates a script option that runs the script under an explicit interpreter instead of the login shell
+* Inputs: interpreter (string) - interpreter command, optionally with flags (e.g. "python3", "/bin/bash -e")
+* Outputs: ScriptOption function that applies the interpreter configuration
+ */
+func WithInterpreter(interpreter string) ScriptOption {
+	return func(config *ScriptConfig) {
+		config.Interpreter = interpreter
+	}
+}
+
+/*
+* Creates a script option that enables or disables honouring the script's #! line
+* Inputs: enabled (bool) - whether to run the script under the interpreter named in its shebang
+* Outputs: ScriptOption function that applies the shebang configuration
+ */
+func WithShebang(enabled bool) ScriptOption {
+	return func(config *ScriptConfig) {
+		config.UseShebang = enabled
+	}
+}
+
+/*
+* Creates a script option that passes positional arguments to the script ($1, $2, ...)
+* Inputs: args (...string) - arguments passed to the script, quoted for the remote shell
+* Outputs: ScriptOption function that applies the argument configuration
+ */
+func WithArgs(args ...string) ScriptOption {
+	return func(config *ScriptConfig) {
+		config.Args = append([]string(nil), args...)
+	}
+}
+
+/*
+* Creates a script option that uploads the script to a remote file, executes it and removes it afterwards
+* Inputs: remoteDir (string) - remote directory for the temporary script file, "" for DefaultScriptConfig.RemoteDir
+* Outputs: ScriptOption function that applies the upload-execute configuration
+ */
+func WithUploadExecute(remoteDir string) ScriptOption {
+	return func(config *ScriptConfig) {
+		config.UploadExecute = true
+		if remoteDir != "" {
+			config.RemoteDir = remoteDir
+		}
+	}
+}
+
+// Sudo Option functions
+
+/*
+* Creates a sudo option that selects the privilege escalation tool
+* Inputs: method (EscalationMethod) - EscalateSudo, EscalateSu or EscalateDoas
+* Outputs: SudoOption function that applies the method configuration
+ */
+func WithEscalationMethod(method EscalationMethod) SudoOption {
+	return func(config *SudoConfig) {
+		config.Method = method
+	}
+}
+
+/*
+* Creates a sudo option that sets the user the command runs as
+* Inputs: user (string) - target user name
+* Outputs: SudoOption function that applies the user configuration
+ */
+func WithSudoUser(user string) SudoOption {
+	return func(config *SudoConfig) {
+		config.User = user
+	}
+}
+
+/*
+* Creates a sudo option that sets the provider asked for the password when prompted
+* Inputs: provider (CredentialProvider) - password source
+* Outputs: SudoOption function that applies the credential configuration
+ */
+func WithCredentials(provider CredentialProvider) SudoOption {
+	return func(config *SudoConfig) {
+		config.Credentials = provider
+	}
+}
+
+/*
+* Creates a sudo option that answers password prompts with a fixed password
+* Inputs: password (string) - password to send when prompted
+* Outputs: SudoOption function that applies the credential configuration
+ */
+func WithSudoPassword(password string) SudoOption {
+	return WithCredentials(StaticPassword(password))
+}
+
+/*
+* Creates a record option that sets the initial terminal size and type written to the header
+* Inputs: tc (*TerminalConfig) - terminal configuration of the recorded shell, nil keeps the defaults
+* Outputs: RecordOption function that applies the terminal configuration
+ */
+func WithRecordTerminal(tc *TerminalConfig) RecordOption {
+	return func(config *RecordConfig) {
+		if tc == nil {
+			return
+		}
+		config.Width = tc.Width
+		config.Height = tc.Height
+		config.Term = tc.Term
+	}
+}
+
+/*
+* Creates a record option that enables or disables recording of typed input
+* Inputs: enabled (bool) - whether to record "i" events
+* Outputs: RecordOption function that applies the input configuration
+ */
+func WithRecordInput(enabled bool) RecordOption {
+	return func(config *RecordConfig) {
+		config.RecordInput = enabled
+	}
+}
+
+/*
+* Creates a record option that sets the recording title
+* Inputs: title (string) - title stored in the header
+* Outputs: RecordOption function that applies the title configuration
+ */
+func WithRecordTitle(title string) RecordOption {
+	return func(config *RecordConfig) {
+		config.Title = title
+	}
+}
+
+/*
+* Creates a session option that selects the terminal multiplexer
+* Inputs: multiplexer (Multiplexer) - MultiplexerTmux, MultiplexerScreen or MultiplexerAuto to detect it on the remote host
+* Outputs: SessionOption function that applies the multiplexer configuration
+ */
+func WithMultiplexer(multiplexer Multiplexer) SessionOption {
+	return func(config *SessionConfig) {
+		config.Multiplexer = multiplexer
+	}
+}
+
+/*
+* Creates a share option that lets observers type into the shared shell
+* Inputs: enabled (bool) - whether observer input is forwarded to the shell
+* Outputs: ShareOption function that applies the input configuration
+ */
+func WithObserverInput(enabled bool) ShareOption {
+	return func(config *ShareConfig) {
+		config.AllowInput = enabled
+	}
+}
+
+/*
+* Creates a share option that sets how much output may queue up for a slow observer before it is disconnected
+* Inputs: length (int) - number of buffered output chunks per observer
+* Outputs: ShareOption function that applies the queue configuration
+ */
+func WithObserverQueue(length int) ShareOption {
+	return func(config *ShareConfig) {
+		config.QueueLength = length
+	}
+}
+
+/*
+* Creates a forward option that reports connections that could not be forwarded
+* Inputs: handler (func(error)) - called from the forwarding goroutines with each connection error
+* Outputs: ForwardOption function that applies the error handler
+ */
+func WithForwardErrorHandler(handler func(err error)) ForwardOption {
+	return func(config *ForwardConfig) {
+		config.ErrorHandler = handler
+	}
+}
+
+/*
+* Creates a job option that sets the remote directory holding the job directories
+* Inputs: dir (string) - remote directory, relative paths start at the login directory
+* Outputs: JobOption function that applies the directory configuration
+ */
+func WithJobDir(dir string) JobOption {
+	return func(config *JobConfig) {
+		config.Dir = dir
+	}
+}
+
+/*
+* Creates a job option that sets the working directory of the command
+* Inputs: dir (string) - remote working directory
+* Outputs: JobOption function that applies the working directory configuration
+ */
+func WithJobWorkDir(dir string) JobOption {
+	return func(config *JobConfig) {
+		config.WorkDir = dir
+	}
+}
+
+/*
+* Creates a job option that sets how often Wait checks the job status
+* Inputs: interval (time.Duration) - time between status checks
+* Outputs: JobOption function that applies the poll interval configuration
+ */
+func WithJobPollInterval(interval time.Duration) JobOption {
+	return func(config *JobConfig) {
+		config.PollInterval = interval
+	}
+}
+
+/*
+* Creates an expect option that sets how long Expect waits for a match
+* Inputs: timeout (time.Duration) - default wait for Expect calls
+* Outputs: ExpectOption function that applies the timeout configuration
+ */
+func WithExpectTimeout(timeout time.Duration) ExpectOption {
+	return func(config *ExpectConfig) {
+		config.Timeout = timeout
+	}
+}
+
+/*
+* Creates an expect option that copies all shell output to a writer, e.g. os.Stdout to watch the interaction
+* Inputs: w (io.Writer) - destination for the shell output
+* Outputs: ExpectOption function that applies the log configuration
+ */
+func WithExpectLog(w io.Writer) ExpectOption {
+	return func(config *ExpectConfig) {
+		config.Log = w
+	}
+}
+
+/*
+* Creates an expect option that limits how much unmatched output is kept for matching
+* Inputs: size (int) - maximum buffered bytes
+* Outputs: ExpectOption function that applies the buffer configuration
+ */
+func WithExpectMaxBuffer(size int) ExpectOption {
+	return func(config *ExpectConfig) {
+		config.MaxBuffer = size
+	}
+}
@@ -187,3 +187,66 @@ func TestShellTypes(t *testing.T) {
 		}
 	}
 }
+
+/*
+* Tests that script option functions apply their configuration on top of the defaults
+* Inputs: t (*testing.T) - testing context
+* Outputs: none (fails test if script options don't work correctly)
+ */
+func TestScriptOptions(t *testing.T) {
+	config := newScriptConfig(nil)
+	if config.RemoteDir != "/tmp" || config.UploadExecute {
+		t.Errorf("Unexpected default script config: %+v", config)
+	}
+
+	args := []string{"a", "b"}
+	config = newScriptConfig([]ScriptOption{
+		WithInterpreter("python3"),
+		WithShebang(true),
+		WithArgs(args...),
+		WithUploadExecute("/var/tmp"),
+	})
+	args[0] = "changed"
+
+	if config.Interpreter != "python3" {
+		t.Errorf("Expected interpreter python3, got %q", config.Interpreter)
+	}
+	if !config.UseShebang {
+		t.Error("Expected UseShebang to be true")
+	}
+	if len(config.Args) != 2 || config.Args[0] != "a" {
+		t.Errorf("Expected args to be copied, got %v", config.Args)
+	}
+	if !config.UploadExecute || config.RemoteDir != "/var/tmp" {
+		t.Errorf("Expected upload-execute in /var/tmp, got %+v", config)
+	}
+
+	if DefaultScriptConfig.RemoteDir != "/tmp" {
+		t.Error("Options must not modify DefaultScriptConfig")
+	}
+}
+
+/*
+* Tests that sudo option functions apply their configuration
+* Inputs: t (*testing.T) - testing context
+* Outputs: none (fails test if sudo options don't work correctly)
+ */
+func TestSudoOptions(t *testing.T) {
+	rs := &remoteScript{scriptType: CommandLine, script: "id"}
+	rs.Sudo(WithEscalationMethod(EscalateDoas), WithSudoUser("admin"), WithSudoPassword("pw"))
+
+	if rs.sudoConfig.Method != EscalateDoas {
+		t.Errorf("Expected doas, got %q", rs.sudoConfig.Method)
+	}
+	if rs.sudoConfig.User != "admin" {
+		t.Errorf("Expected user admin, got %q", rs.sudoConfig.User)
+	}
+	if password, _ := rs.sudoConfig.Credentials.Password(); password != "pw" {
+		t.Errorf("Expected password pw, got %q", password)
+	}
+
+	rs.Sudo()
+	if rs.sudoConfig.Method != EscalateSudo || rs.sudoConfig.User != "root" || rs.sudoConfig.Credentials != nil {
+		t.Errorf("Expected default sudo config, got %+v", rs.sudoConfig)
+	}
+}
@@ -0,0 +1,548 @@
+package dingo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Persistent session errors
+var (
+	ErrNoMultiplexer      = errors.New("neither tmux nor screen is installed on the remote host")
+	ErrInvalidSessionName = errors.New("invalid session name: use letters, digits, '-' and '_'")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrUnknownMultiplexer = errors.New("unknown terminal multiplexer")
+)
+
+// multiplexerDetectCommand prints the installed multiplexers, preferred first
+const multiplexerDetectCommand = "for m in tmux screen; do command -v $m >/dev/null 2>&1 && echo $m; done; true"
+
+var (
+	sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// screenListPattern matches "\t1234.name\t(date)\t(Detached)" lines of screen -ls
+	screenListPattern = regexp.MustCompile(`^\s+\d+\.(\S+)\s.*\(([^()]*)\)\s*$`)
+	// screenStuffReplacer escapes the characters screen interprets in the stuff command
+	screenStuffReplacer = strings.NewReplacer(`\`, `\\`, `^`, `\^`, `$`, `\$`)
+)
+
+// persistentSession implements the PersistentSession interface on top of tmux or screen
+type persistentSession struct {
+	client      *client
+	name        string
+	multiplexer Multiplexer // Resolved lazily when configured as MultiplexerAuto
+	err         error
+}
+
+/*
+* Creates a handle for a named persistent session, nothing is started on the remote host until a method is called
+* Inputs: name (string) - session name, opts (...SessionOption) - multiplexer selection
+* Outputs: PersistentSession interface for managing the session
+ */
+func (c *client) PersistentSession(name string, opts ...SessionOption) PersistentSession {
+	config := newSessionConfig(opts)
+
+	ps := &persistentSession{
+		client:      c,
+		name:        name,
+		multiplexer: config.Multiplexer,
+	}
+	if !sessionNamePattern.MatchString(name) {
+		ps.err = fmt.Errorf("%w: %q", ErrInvalidSessionName, name)
+	}
+	return ps
+}
+
+/*
+* Lists the persistent sessions on the remote host
+* Inputs: opts (...SessionOption) - multiplexer selection, MultiplexerAuto lists both tmux and screen sessions
+* Outputs: []SessionInfo describing the sessions, error if no multiplexer is installed or listing fails
+ */
+func (c *client) ListSessions(opts ...SessionOption) ([]SessionInfo, error) {
+	config := newSessionConfig(opts)
+
+	multiplexers := []Multiplexer{config.Multiplexer}
+	if config.Multiplexer == MultiplexerAuto {
+		installed, err := c.installedMultiplexers()
+		if err != nil {
+			return nil, err
+		}
+		multiplexers = installed
+	}
+
+	var sessions []SessionInfo
+	for _, multiplexer := range multiplexers {
+		found, err := c.listSessions(multiplexer)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, found...)
+	}
+	return sessions, nil
+}
+
+/*
+* Internal helper that builds a session configuration from the defaults and the given options
+* Inputs: opts ([]SessionOption) - session options to apply
+* Outputs: *SessionConfig containing the resulting configuration
+ */
+func newSessionConfig(opts []SessionOption) *SessionConfig {
+	config := *DefaultSessionConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return &config
+}
+
+/*
+* Internal helper that reports which multiplexers are installed on the remote host, tmux first
+* Inputs: none
+* Outputs: []Multiplexer containing the installed multiplexers, ErrNoMultiplexer if there are none
+ */
+func (c *client) installedMultiplexers() ([]Multiplexer, error) {
+	output, err := c.Command(multiplexerDetectCommand).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect terminal multiplexer: %w", err)
+	}
+
+	var installed []Multiplexer
+	for _, line := range strings.Fields(string(output)) {
+		installed = append(installed, Multiplexer(line))
+	}
+	if len(installed) == 0 {
+		return nil, ErrNoMultiplexer
+	}
+	return installed, nil
+}
+
+/*
+* Internal helper that lists the sessions of one multiplexer
+* Inputs: multiplexer (Multiplexer) - tmux or screen
+* Outputs: []SessionInfo describing the sessions, error if the listing command cannot run
+ */
+func (c *client) listSessions(multiplexer Multiplexer) ([]SessionInfo, error) {
+	var command string
+	switch multiplexer {
+	case MultiplexerTmux:
+		// tmux exits non-zero when no server is running, which just means there are no sessions
+		command = `tmux list-sessions -F '#{session_name} #{session_attached}' 2>/dev/null || true`
+	case MultiplexerScreen:
+		// screen -ls exits non-zero even when it lists sessions
+		command = `screen -ls 2>/dev/null || true`
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMultiplexer, multiplexer)
+	}
+
+	output, err := c.Command(command).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s sessions: %w", multiplexer, err)
+	}
+	if multiplexer == MultiplexerTmux {
+		return parseTmuxSessions(string(output)), nil
+	}
+	return parseScreenSessions(string(output)), nil
+}
+
+/*
+* Internal helper that parses "name attached-count" lines printed by tmux list-sessions
+* Inputs: output (string) - command output
+* Outputs: []SessionInfo describing the sessions
+ */
+func parseTmuxSessions(output string) []SessionInfo {
+	var sessions []SessionInfo
+	for _, line := range strings.Split(output, "\n") {
+		i := strings.LastIndex(line, " ")
+		if i <= 0 {
+			continue
+		}
+		sessions = append(sessions, SessionInfo{
+			Name:        line[:i],
+			Multiplexer: MultiplexerTmux,
+			Attached:    line[i+1:] != "0",
+		})
+	}
+	return sessions
+}
+
+/*
+* Internal helper that parses the session lines printed by screen -ls
+* Inputs: output (string) - command output, e.g. "\t1234.dingo\t(Detached)"
+* Outputs: []SessionInfo describing the sessions, the pid prefix is removed from the names
+ */
+func parseScreenSessions(output string) []SessionInfo {
+	var sessions []SessionInfo
+	for _, line := range strings.Split(output, "\n") {
+		m := screenListPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		state := strings.ToLower(m[2])
+		sessions = append(sessions, SessionInfo{
+			Name:        m[1],
+			Multiplexer: MultiplexerScreen,
+			Attached:    strings.Contains(state, "attached") && !strings.Contains(state, "detached"),
+		})
+	}
+	return sessions
+}
+
+/*
+* Returns the session name
+* Inputs: none
+* Outputs: string containing the name
+ */
+func (ps *persistentSession) Name() string {
+	return ps.name
+}
+
+/*
+* Returns the multiplexer used for the session, detecting it on the remote host when configured as MultiplexerAuto
+* Inputs: none
+* Outputs: Multiplexer in use, error if none is installed or the name is invalid
+ */
+func (ps *persistentSession) Multiplexer() (Multiplexer, error) {
+	if ps.err != nil {
+		return "", ps.err
+	}
+	if ps.multiplexer != MultiplexerAuto {
+		return ps.multiplexer, nil
+	}
+
+	installed, err := ps.client.installedMultiplexers()
+	if err != nil {
+		return "", err
+	}
+	ps.multiplexer = installed[0]
+	return ps.multiplexer, nil
+}
+
+/*
+* Starts the session detached on the remote host
+* Inputs: command (string) - shell command run in the session, "" for the default shell
+* Outputs: error if the session cannot be created, e.g. because it already exists
+ */
+func (ps *persistentSession) Create(command string) error {
+	multiplexer, err := ps.Multiplexer()
+	if err != nil {
+		return err
+	}
+
+	name := shellQuote(ps.name)
+	switch multiplexer {
+	case MultiplexerTmux:
+		return ps.run("create", appendCommand("tmux new-session -d -s "+name, command))
+	case MultiplexerScreen:
+		return ps.run("create", appendCommand("screen -dmS "+name, command))
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownMultiplexer, multiplexer)
+	}
+}
+
+/*
+* Reports whether the session is running on the remote host
+* Inputs: none
+* Outputs: bool - true if the session exists, error if the sessions cannot be listed
+ */
+func (ps *persistentSession) Exists() (bool, error) {
+	multiplexer, err := ps.Multiplexer()
+	if err != nil {
+		return false, err
+	}
+
+	sessions, err := ps.client.listSessions(multiplexer)
+	if err != nil {
+		return false, err
+	}
+	for _, session := range sessions {
+		if session.Name == ps.name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+/*
+* Creates an interactive shell that attaches to the session, creating it first if it does not exist
+* Start(command) uses command only when the session has to be created, ShellExec types its commands into the session before attaching
+* Inputs: config (*TerminalConfig) - terminal configuration or nil for defaults
+* Outputs: Shell interface that can be run with RunInLocalTerminal or RecordShell
+ */
+func (ps *persistentSession) Attach(config *TerminalConfig) Shell {
+	return &attachedShell{
+		shell:   ps.client.InteractiveShell(config),
+		session: ps,
+	}
+}
+
+/*
+* Detaches every client attached to the session, leaving it running
+* Inputs: none
+* Outputs: error if the session does not exist or detaching fails
+ */
+func (ps *persistentSession) Detach() error {
+	return ps.control("detach",
+		"tmux detach-client -s "+tmuxSession(ps.name),
+		screenSessionCommand(ps.name, `screen -d "$s"`))
+}
+
+/*
+* Terminates the session and the programs running in it
+* Inputs: none
+* Outputs: error if the session does not exist or cannot be killed
+ */
+func (ps *persistentSession) Kill() error {
+	return ps.control("kill",
+		"tmux kill-session -t "+tmuxSession(ps.name),
+		screenSessionCommand(ps.name, `screen -S "$s" -X quit`))
+}
+
+/*
+* Types text into the session as if entered on its terminal, "\n" presses Enter
+* Inputs: keys (string) - literal text to send
+* Outputs: error if the session does not exist or the keys cannot be sent
+ */
+func (ps *persistentSession) SendKeys(keys string) error {
+	return ps.control("send keys to", tmuxSendKeys(ps.name, keys),
+		screenSessionCommand(ps.name, `screen -S "$s" -p 0 -X stuff `+shellQuote(screenStuff(keys))))
+}
+
+/*
+* Returns the text currently visible in the session without attaching to it
+* Inputs: none
+* Outputs: string containing the screen contents, error if the session does not exist or capturing fails
+ */
+func (ps *persistentSession) Capture() (string, error) {
+	multiplexer, err := ps.Multiplexer()
+	if err != nil {
+		return "", err
+	}
+
+	var command string
+	switch multiplexer {
+	case MultiplexerTmux:
+		command = "tmux capture-pane -p -J -t " + tmuxPane(ps.name)
+	case MultiplexerScreen:
+		// hardcopy is carried out asynchronously by the screen server, so wait for the file to be written
+		command = screenSessionCommand(ps.name, `f=$(mktemp) || exit 1; screen -S "$s" -p 0 -X hardcopy "$f" && for i in 1 2 3 4 5 6 7 8 9 10; do [ -s "$f" ] && break; sleep 0.1; done; cat "$f"; status=$?; rm -f "$f"; exit $status`)
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownMultiplexer, multiplexer)
+	}
+
+	output, err := ps.client.Command(command).SmartOutput()
+	if err != nil {
+		return "", ps.failure("capture", err, output)
+	}
+	return string(output), nil
+}
+
+/*
+* Internal helper that runs the control command matching the session's multiplexer
+* Inputs: op (string) - operation name for errors, tmuxCommand (string) - command for tmux, screenCommand (string) - command for screen
+* Outputs: error if the command fails
+ */
+func (ps *persistentSession) control(op, tmuxCommand, screenCommand string) error {
+	multiplexer, err := ps.Multiplexer()
+	if err != nil {
+		return err
+	}
+
+	switch multiplexer {
+	case MultiplexerTmux:
+		return ps.run(op, tmuxCommand)
+	case MultiplexerScreen:
+		return ps.run(op, screenCommand)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownMultiplexer, multiplexer)
+	}
+}
+
+/*
+* Internal helper that runs a multiplexer command on the remote host
+* Inputs: op (string) - operation name for errors, command (string) - remote command line
+* Outputs: error describing the failure, nil on success
+ */
+func (ps *persistentSession) run(op, command string) error {
+	output, err := ps.client.Command(command).SmartOutput()
+	if err != nil {
+		return ps.failure(op, err, output)
+	}
+	return nil
+}
+
+/*
+* Internal helper that converts a failed multiplexer command into an error, reporting ErrSessionNotFound for missing sessions
+* Inputs: op (string) - operation name, err (error) - command error, output ([]byte) - stderr of the command
+* Outputs: error describing the failure
+ */
+func (ps *persistentSession) failure(op string, err error, output []byte) error {
+	if op != "create" {
+		if exists, existsErr := ps.Exists(); existsErr == nil && !exists {
+			return fmt.Errorf("failed to %s %s session %q: %w", op, ps.multiplexer, ps.name, ErrSessionNotFound)
+		}
+	}
+	return fmt.Errorf("failed to %s %s session %q: %w: %s", op, ps.multiplexer, ps.name, err, strings.TrimSpace(string(output)))
+}
+
+/*
+* Internal helper that builds the command attaching to the session, creating it when it does not exist
+* Inputs: command (string) - shell command for a newly created session, "" for the default shell
+* Outputs: string containing the remote command line, error if the multiplexer cannot be determined
+ */
+func (ps *persistentSession) attachCommand(command string) (string, error) {
+	multiplexer, err := ps.Multiplexer()
+	if err != nil {
+		return "", err
+	}
+	exists, err := ps.Exists()
+	if err != nil {
+		return "", err
+	}
+
+	name := shellQuote(ps.name)
+	switch {
+	case multiplexer == MultiplexerTmux && exists:
+		return "tmux attach-session -t " + tmuxSession(ps.name), nil
+	case multiplexer == MultiplexerTmux:
+		return appendCommand("tmux new-session -s "+name, command), nil
+	case multiplexer == MultiplexerScreen && exists:
+		// -x joins the session even when another terminal is attached to it
+		return screenSessionCommand(ps.name, `screen -x "$s"`), nil
+	case multiplexer == MultiplexerScreen:
+		return appendCommand("screen -S "+name, command), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownMultiplexer, multiplexer)
+	}
+}
+
+/*
+* Internal helper that appends a shell command as the program a multiplexer starts
+* Inputs: base (string) - multiplexer command line, command (string) - shell command or "" for the default shell
+* Outputs: string containing the combined command line
+ */
+func appendCommand(base, command string) string {
+	if command == "" {
+		return base
+	}
+	return base + " sh -c " + shellQuote(command)
+}
+
+/*
+* Internal helper that builds a command addressing exactly the screen session called name
+* screen -S and -x accept any session whose name starts with the given one, so the full "pid.name" is looked up
+* in screen -ls first and stored in $s for the command
+* Inputs: name (string) - session name, command (string) - screen command line using "$s" as the session
+* Outputs: string containing the remote command line, which fails if the session does not exist
+ */
+func screenSessionCommand(name, command string) string {
+	return `s=$(screen -ls 2>/dev/null | awk -v name=` + shellQuote(name) + ` '{ id = $1 } sub(/^[0-9]+\./, "", id) && id == name { print $1; exit }'); ` +
+		`[ -n "$s" ] || { echo "No screen session found." >&2; exit 1; }; ` + command
+}
+
+/*
+* Internal helper that builds the tmux command typing literal text, sending newlines as the Enter key
+* Inputs: name (string) - session name, keys (string) - text to send
+* Outputs: string containing the remote command line
+ */
+func tmuxSendKeys(name, keys string) string {
+	// Remote commands must fit on one line, so newlines become separate Enter key presses
+	var commands []string
+	for i, line := range strings.Split(keys, "\n") {
+		if i > 0 {
+			commands = append(commands, "send-keys -t "+tmuxPane(name)+" Enter")
+		}
+		if line != "" {
+			commands = append(commands, "send-keys -t "+tmuxPane(name)+" -l -- "+shellQuote(line))
+		}
+	}
+	return "tmux " + strings.Join(commands, ` \; `)
+}
+
+/*
+* Internal helper that escapes text for the screen stuff command, sending newlines as carriage returns
+* Inputs: keys (string) - text to send
+* Outputs: string containing the escaped text
+ */
+func screenStuff(keys string) string {
+	return strings.ReplaceAll(screenStuffReplacer.Replace(keys), "\n", "^M")
+}
+
+/*
+* Internal helper that builds an exact-match tmux session target
+* Inputs: name (string) - session name
+* Outputs: string containing the quoted target
+ */
+func tmuxSession(name string) string {
+	// "=" disables tmux prefix matching so "web" never addresses "web2"
+	return shellQuote("=" + name)
+}
+
+/*
+* Internal helper that builds an exact-match tmux target for the active pane of a session
+* Inputs: name (string) - session name
+* Outputs: string containing the quoted target
+ */
+func tmuxPane(name string) string {
+	return shellQuote("=" + name + ":")
+}
+
+// attachedShell is an interactive shell that attaches to a persistent session
+type attachedShell struct {
+	shell   Shell
+	session *persistentSession
+}
+
+/*
+* Attaches to the session, creating it with the given command if it does not exist
+* Inputs: command (string) - program for a newly created session, "" for the default shell
+* Outputs: error if the session cannot be attached, nil once the terminal detaches or the session ends
+ */
+func (as *attachedShell) Start(command string) error {
+	attach, err := as.session.attachCommand(command)
+	if err != nil {
+		return err
+	}
+	return as.shell.Start(attach)
+}
+
+/*
+* Types the commands into the session, creating it if needed, then attaches to it
+* Inputs: commands (...string) - commands sent to the session, one per line
+* Outputs: error if the session cannot be created, reached or attached
+ */
+func (as *attachedShell) ShellExec(commands ...string) error {
+	exists, err := as.session.Exists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := as.session.Create(""); err != nil {
+			return err
+		}
+	}
+	for _, command := range commands {
+		if err := as.session.SendKeys(command + "\n"); err != nil {
+			return err
+		}
+	}
+	return as.Start("")
+}
+
+/*
+* Changes the size of the attached terminal
+* Inputs: width (int) - new width in columns, height (int) - new height in rows
+* Outputs: error if the window-change request fails
+ */
+func (as *attachedShell) Resize(width, height int) error {
+	return as.shell.Resize(width, height)
+}
+
+/*
+* Configures the streams of the attached terminal
+* Inputs: stdin (io.Reader) - input stream, stdout (io.Writer) - output stream, stderr (io.Writer) - error stream
+* Outputs: Shell interface for method chaining
+ */
+func (as *attachedShell) SetStdio(stdin io.Reader, stdout, stderr io.Writer) Shell {
+	as.shell.SetStdio(stdin, stdout, stderr)
+	return as
+}
@@ -0,0 +1,179 @@
+package dingo
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+* Creates a client whose remote commands use a private tmux server, skipping the test if tmux is missing
+ */
+func createTmuxTestClient(t *testing.T) SSHClient {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is not installed")
+	}
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	t.Setenv("TMUX", "")
+	t.Cleanup(func() {
+		exec.Command("tmux", "kill-server").Run()
+	})
+	return newClient(createExecSSHServer(t), nil)
+}
+
+func TestPersistentSession_Tmux_Lifecycle(t *testing.T) {
+	client := createTmuxTestClient(t)
+	session := client.PersistentSession("bench", WithMultiplexer(MultiplexerTmux))
+
+	if exists, err := session.Exists(); err != nil || exists {
+		t.Fatalf("Expected no session yet, got %v, %v", exists, err)
+	}
+	if err := session.Create(""); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// A session whose name starts with the same prefix must not be addressed
+	other := client.PersistentSession("bench2", WithMultiplexer(MultiplexerTmux))
+	if err := other.Create("sleep 60"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if err := session.SendKeys("echo 'marker $HOME ^x'\n"); err != nil {
+		t.Fatalf("SendKeys failed: %v", err)
+	}
+
+	var screen string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var err error
+		if screen, err = session.Capture(); err != nil {
+			t.Fatalf("Capture failed: %v", err)
+		}
+		if strings.Contains(screen, "\nmarker $HOME ^x") {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !strings.Contains(screen, "\nmarker $HOME ^x") {
+		t.Errorf("Command output not captured: %q", screen)
+	}
+
+	sessions, err := client.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0] != (SessionInfo{Name: "bench", Multiplexer: MultiplexerTmux}) || sessions[1].Name != "bench2" {
+		t.Errorf("Unexpected sessions: %+v", sessions)
+	}
+
+	if err := session.Kill(); err != nil {
+		t.Fatalf("Kill failed: %v", err)
+	}
+	if exists, _ := other.Exists(); !exists {
+		t.Error("Killing bench also killed bench2")
+	}
+	if err := session.Kill(); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestPersistentSession_AutoDetect(t *testing.T) {
+	client := createTmuxTestClient(t)
+
+	multiplexer, err := client.PersistentSession("auto").Multiplexer()
+	if err != nil || multiplexer != MultiplexerTmux {
+		t.Errorf("Expected tmux, got %q, %v", multiplexer, err)
+	}
+}
+
+func TestPersistentSession_InvalidName(t *testing.T) {
+	client := newClient(nil, nil)
+
+	for _, name := range []string{"", "a b", "x;rm -rf /", "web:1", "a.b"} {
+		session := client.PersistentSession(name)
+		if err := session.Create(""); !errors.Is(err, ErrInvalidSessionName) {
+			t.Errorf("Name %q: expected ErrInvalidSessionName, got %v", name, err)
+		}
+	}
+}
+
+func TestPersistentSession_AttachCommand(t *testing.T) {
+	client := createTmuxTestClient(t)
+	session := client.PersistentSession("work", WithMultiplexer(MultiplexerTmux)).(*persistentSession)
+
+	command, err := session.attachCommand("htop")
+	if err != nil || command != "tmux new-session -s 'work' sh -c 'htop'" {
+		t.Errorf("Unexpected create command %q, %v", command, err)
+	}
+
+	if err := session.Create(""); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	command, err = session.attachCommand("htop")
+	if err != nil || command != "tmux attach-session -t '=work'" {
+		t.Errorf("Unexpected attach command %q, %v", command, err)
+	}
+}
+
+func TestPersistentSession_Screen_ExactName(t *testing.T) {
+	// A fake screen lists sessions whose names share a prefix and records the other invocations
+	dir := t.TempDir()
+	fake := "#!/bin/sh\n" +
+		"if [ \"$1\" = -ls ]; then printf 'There are screens on:\\n\\t4242.dingo-alice\\t(Detached)\\n\\t4343.dingo\\t(Detached)\\n'; exit 1; fi\n" +
+		"echo \"$@\" >> " + filepath.Join(dir, "calls") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "screen"), []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	client := newClient(createExecSSHServer(t), nil)
+
+	session := client.PersistentSession("dingo", WithMultiplexer(MultiplexerScreen))
+	if err := session.Kill(); err != nil {
+		t.Fatalf("Kill failed: %v", err)
+	}
+	if err := session.SendKeys("ls\n"); err != nil {
+		t.Fatalf("SendKeys failed: %v", err)
+	}
+	calls, _ := os.ReadFile(filepath.Join(dir, "calls"))
+	if expected := "-S 4343.dingo -X quit\n-S 4343.dingo -p 0 -X stuff ls^M\n"; string(calls) != expected {
+		t.Errorf("Expected commands for 4343.dingo, got %q", calls)
+	}
+
+	err := client.PersistentSession("ding", WithMultiplexer(MultiplexerScreen)).Kill()
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound for a name prefix, got %v", err)
+	}
+}
+
+func TestParseScreenSessions(t *testing.T) {
+	output := "There are screens on:\n" +
+		"\t4242.dingo-alice\t(10/18/2026 09:12:01 AM)\t(Detached)\n" +
+		"\t4343.build\t(Attached)\n" +
+		"\t4444.shared\t(Multi, attached)\n" +
+		"3 Sockets in /run/screen/S-alice.\n"
+
+	sessions := parseScreenSessions(output)
+	expected := []SessionInfo{
+		{Name: "dingo-alice", Multiplexer: MultiplexerScreen, Attached: false},
+		{Name: "build", Multiplexer: MultiplexerScreen, Attached: true},
+		{Name: "shared", Multiplexer: MultiplexerScreen, Attached: true},
+	}
+	if len(sessions) != len(expected) {
+		t.Fatalf("Expected %d sessions, got %+v", len(expected), sessions)
+	}
+	for i := range expected {
+		if sessions[i] != expected[i] {
+			t.Errorf("Session %d: expected %+v, got %+v", i, expected[i], sessions[i])
+		}
+	}
+}
+
+func TestScreenStuff(t *testing.T) {
+	if got := screenStuff("echo $HOME ^C \\n\n"); got != `echo \$HOME \^C \\n^M` {
+		t.Errorf("Unexpected escaping: %q", got)
+	}
+}
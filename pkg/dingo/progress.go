@@ -0,0 +1,123 @@
+package dingo
+
+import (
+	"sync"
+	"time"
+)
+
+// transferTracker accumulates the progress of a transfer and passes it to the configured callback
+type transferTracker struct {
+	report   func(TransferProgress)
+	interval time.Duration
+
+	mu       sync.Mutex
+	progress TransferProgress
+	skipped  int64 // Bytes found at the destination by resumed copies, not counted in the rate
+	start    time.Time
+	last     time.Time // When progress was last reported
+}
+
+/*
+* Internal helper that starts tracking a transfer
+* Inputs: config (*TransferConfig) - transfer configuration holding the callback, files (int) - number of files, size (int64) - total size of the files in bytes
+* Outputs: *transferTracker for the transfer, its methods do nothing without a callback
+ */
+func newTransferTracker(config *TransferConfig, files int, size int64) *transferTracker {
+	now := time.Now()
+	return &transferTracker{
+		report:   config.Progress,
+		interval: config.ProgressInterval,
+		progress: TransferProgress{FilesTotal: files, BytesTotal: size},
+		start:    now,
+		last:     now,
+	}
+}
+
+/*
+* Internal helper that records the file whose contents are being copied
+* Inputs: name (string) - source path of the file
+* Outputs: none
+ */
+func (t *transferTracker) startFile(name string) {
+	if t.report == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.File = name
+}
+
+/*
+* Internal helper that records a completely copied file
+* Inputs: none
+* Outputs: none
+ */
+func (t *transferTracker) endFile() {
+	if t.report == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.FilesDone++
+}
+
+/*
+* Internal helper that records copied bytes, progress is reported at most once per interval
+* Inputs: n (int64) - number of bytes copied
+* Outputs: none
+ */
+func (t *transferTracker) add(n int64) {
+	if t.report == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.BytesDone += n
+	if now := time.Now(); now.Sub(t.last) >= t.interval {
+		t.send(now)
+	}
+}
+
+/*
+* Internal helper that records bytes a resumed copy did not need to transfer
+* Inputs: n (int64) - number of bytes already at the destination
+* Outputs: none
+ */
+func (t *transferTracker) skip(n int64) {
+	if t.report == nil || n == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.BytesDone += n
+	t.skipped += n
+}
+
+/*
+* Internal helper that reports the final progress of a completed transfer
+* Inputs: none
+* Outputs: none
+ */
+func (t *transferTracker) finish() {
+	if t.report == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.send(time.Now())
+}
+
+/*
+* Internal helper that passes the current progress to the callback, the caller holds the lock
+* Inputs: now (time.Time) - current time
+* Outputs: none
+ */
+func (t *transferTracker) send(now time.Time) {
+	t.last = now
+	t.progress.Elapsed = now.Sub(t.start)
+	t.progress.Rate = 0
+	if seconds := t.progress.Elapsed.Seconds(); seconds > 0 {
+		t.progress.Rate = float64(t.progress.BytesDone-t.skipped) / seconds
+	}
+	t.report(t.progress)
+}
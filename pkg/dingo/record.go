@@ -0,0 +1,300 @@
+package dingo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// passwordPromptPattern matches output ending in a password prompt, after which typed input is redacted
+var passwordPromptPattern = regexp.MustCompile(`(?i)(password|passphrase)[^\n]*:\s*$`)
+
+// promptTailSize is how much recent output is kept to find password prompts split across writes
+const promptTailSize = 256
+
+// asciicastHeader is the first line of an asciicast v2 file
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// recordedShell wraps a Shell and writes its terminal traffic to an asciicast v2 stream
+type recordedShell struct {
+	shell  Shell
+	config RecordConfig
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	mu          sync.Mutex
+	w           io.Writer
+	started     bool
+	start       time.Time
+	redactInput bool
+	outputTail  string // Recent output searched for password prompts
+	err         error
+}
+
+/*
+* Wraps a shell so its output, and optionally its input, is recorded in asciicast v2 format
+* Typed input following a password prompt is never recorded
+* Inputs: shell (Shell) - shell to record, w (io.Writer) - destination for the .cast data, opts (...RecordOption) - recording options
+* Outputs: Shell interface that records while delegating to the wrapped shell
+ */
+func RecordShell(shell Shell, w io.Writer, opts ...RecordOption) Shell {
+	config := *DefaultRecordConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return &recordedShell{
+		shell:  shell,
+		config: config,
+		w:      w,
+	}
+}
+
+/*
+* Starts the wrapped shell with recording streams
+* Inputs: command (string) - program to run instead of the login shell, "" for the shell
+* Outputs: error from the wrapped shell or from writing the recording
+ */
+func (r *recordedShell) Start(command string) error {
+	if err := r.attach(); err != nil {
+		return err
+	}
+	return r.result(r.shell.Start(command))
+}
+
+/*
+* Starts the wrapped login shell with initial commands and recording streams
+* Inputs: commands (...string) - initial commands sent to the shell
+* Outputs: error from the wrapped shell or from writing the recording
+ */
+func (r *recordedShell) ShellExec(commands ...string) error {
+	if err := r.attach(); err != nil {
+		return err
+	}
+	return r.result(r.shell.ShellExec(commands...))
+}
+
+/*
+* Configures the streams of the recorded shell
+* Inputs: stdin (io.Reader) - input stream, stdout (io.Writer) - output stream, stderr (io.Writer) - error stream
+* Outputs: Shell interface for method chaining
+ */
+func (r *recordedShell) SetStdio(stdin io.Reader, stdout, stderr io.Writer) Shell {
+	r.stdin = stdin
+	r.stdout = stdout
+	r.stderr = stderr
+	return r
+}
+
+/*
+* Records a resize event and forwards the new size to the wrapped shell
+* Inputs: width (int) - new width in columns, height (int) - new height in rows
+* Outputs: error if the wrapped shell fails to resize
+ */
+func (r *recordedShell) Resize(width, height int) error {
+	r.mu.Lock()
+	if r.started {
+		r.writeEvent("r", fmt.Sprintf("%dx%d", width, height))
+	} else {
+		r.config.Width = width
+		r.config.Height = height
+	}
+	r.mu.Unlock()
+
+	return r.shell.Resize(width, height)
+}
+
+/*
+* Internal helper that writes the header and hands recording streams to the wrapped shell
+* Inputs: none
+* Outputs: error if the header cannot be written
+ */
+func (r *recordedShell) attach() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.started {
+		r.start = time.Now()
+		header := asciicastHeader{
+			Version:   2,
+			Width:     r.config.Width,
+			Height:    r.config.Height,
+			Timestamp: r.start.Unix(),
+			Title:     r.config.Title,
+			Env:       map[string]string{"TERM": r.config.Term},
+		}
+		if err := r.writeLine(header); err != nil {
+			return err
+		}
+		r.started = true
+	}
+
+	stdin, stdout, stderr := r.stdin, r.stdout, r.stderr
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	if r.config.RecordInput {
+		stdin = &recordReader{r: stdin, rec: r}
+	}
+	r.shell.SetStdio(stdin, &recordWriter{w: stdout, rec: r}, &recordWriter{w: stderr, rec: r})
+	return nil
+}
+
+/*
+* Internal helper that combines the shell result with any recording error
+* Inputs: err (error) - result of the wrapped shell
+* Outputs: error - the shell error, or the first recording error if the shell succeeded
+ */
+func (r *recordedShell) result(err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err == nil && r.err != nil {
+		return fmt.Errorf("session recording failed: %w", r.err)
+	}
+	return err
+}
+
+/*
+* Internal helper that records terminal output and arms input redaction after password prompts
+* Inputs: data (string) - output text
+* Outputs: none
+ */
+func (r *recordedShell) recordOutput(data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.writeEvent("o", data)
+	// Prompts can arrive in several writes, so the end of the recent output is matched
+	r.outputTail += data
+	if len(r.outputTail) > promptTailSize {
+		r.outputTail = r.outputTail[len(r.outputTail)-promptTailSize:]
+	}
+	if passwordPromptPattern.MatchString(r.outputTail) {
+		r.redactInput = true
+		r.outputTail = "" // The echoed newline after the password must not match the same prompt again
+	}
+}
+
+/*
+* Internal helper that records typed input, dropping everything typed after a password prompt up to the end of the line
+* Inputs: data ([]byte) - input bytes
+* Outputs: none
+ */
+func (r *recordedShell) recordInput(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.redactInput {
+		for i, b := range data {
+			if b == '\r' || b == '\n' {
+				r.redactInput = false
+				data = data[i:]
+				break
+			}
+		}
+		if r.redactInput {
+			return
+		}
+	}
+	r.writeEvent("i", string(data))
+}
+
+/*
+* Internal helper that writes a timed event line, the caller must hold r.mu
+* Inputs: kind (string) - event type ("o", "i" or "r"), data (string) - event data
+* Outputs: none (the first write error is kept in r.err)
+ */
+func (r *recordedShell) writeEvent(kind, data string) {
+	if data == "" {
+		return
+	}
+	elapsed := float64(time.Since(r.start).Microseconds()) / 1e6
+	r.writeLine([]any{elapsed, kind, data})
+}
+
+/*
+* Internal helper that writes one JSON line of the recording, the caller must hold r.mu
+* Inputs: v (any) - value to encode
+* Outputs: error if encoding or writing fails
+ */
+func (r *recordedShell) writeLine(v any) error {
+	if r.err != nil {
+		return r.err
+	}
+	line, err := json.Marshal(v)
+	if err == nil {
+		_, err = r.w.Write(append(line, '\n'))
+	}
+	r.err = err
+	return err
+}
+
+// recordWriter records output passing through to the real writer, holding back incomplete UTF-8 sequences
+type recordWriter struct {
+	w       io.Writer
+	rec     *recordedShell
+	pending []byte
+}
+
+/*
+* Writes data to the real writer and records the complete characters
+* Inputs: p ([]byte) - output data
+* Outputs: int containing bytes written, error if the real writer fails
+ */
+func (rw *recordWriter) Write(p []byte) (int, error) {
+	data := append(rw.pending, p...)
+	complete := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+	rw.rec.recordOutput(string(data[:complete]))
+	rw.pending = append([]byte(nil), data[complete:]...)
+
+	return rw.w.Write(p)
+}
+
+// recordReader records input read from the real reader
+type recordReader struct {
+	r   io.Reader
+	rec *recordedShell
+}
+
+/*
+* Reads from the real reader and records what was read
+* Inputs: p ([]byte) - destination buffer
+* Outputs: int containing bytes read, error from the real reader
+ */
+func (rr *recordReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if n > 0 {
+		rr.rec.recordInput(p[:n])
+	}
+	return n, err
+}
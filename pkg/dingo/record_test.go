@@ -0,0 +1,257 @@
+package dingo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// scriptedShell is a Shell that writes fixed output and copies its input, for recorder tests
+type scriptedShell struct {
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	output  []string
+	resizes []string
+}
+
+func (s *scriptedShell) Start(command string) error {
+	for _, out := range s.output {
+		s.stdout.Write([]byte(out))
+		s.readLine()
+	}
+	s.stderr.Write([]byte("err\n"))
+	return nil
+}
+
+// readLine consumes one typed line from stdin, one byte at a time like a terminal
+func (s *scriptedShell) readLine() {
+	if s.stdin == nil {
+		return
+	}
+	b := make([]byte, 1)
+	for {
+		if n, err := s.stdin.Read(b); err != nil || (n == 1 && b[0] == '\n') {
+			return
+		}
+	}
+}
+
+func (s *scriptedShell) ShellExec(commands ...string) error {
+	return s.Start("")
+}
+
+func (s *scriptedShell) SetStdio(stdin io.Reader, stdout, stderr io.Writer) Shell {
+	s.stdin, s.stdout, s.stderr = stdin, stdout, stderr
+	return s
+}
+
+func (s *scriptedShell) Resize(width, height int) error {
+	s.resizes = append(s.resizes, fmt.Sprintf("%dx%d", width, height))
+	return nil
+}
+
+/*
+* Test helper that parses an asciicast v2 recording into its header and events
+* Inputs: t (*testing.T) - test context, data (string) - recording content
+* Outputs: asciicastHeader and event slices of [time, kind, data]
+ */
+func parseCast(t *testing.T, data string) (asciicastHeader, [][]any) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+
+	var header asciicastHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatalf("Invalid header %q: %v", lines[0], err)
+	}
+
+	var events [][]any
+	for _, line := range lines[1:] {
+		var event []any
+		if err := json.Unmarshal([]byte(line), &event); err != nil || len(event) != 3 {
+			t.Fatalf("Invalid event %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return header, events
+}
+
+func TestRecordShell_HeaderAndOutput(t *testing.T) {
+	var cast, stdout, stderr bytes.Buffer
+	inner := &scriptedShell{output: []string{"hello\r\n", "world\r\n"}}
+
+	shell := RecordShell(inner, &cast, WithRecordTerminal(&TerminalConfig{Term: "xterm-256color", Width: 120, Height: 30}), WithRecordTitle("test"))
+	shell.SetStdio(nil, &stdout, &stderr)
+	if err := shell.Start(""); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	header, events := parseCast(t, cast.String())
+	if header.Version != 2 || header.Width != 120 || header.Height != 30 || header.Title != "test" || header.Env["TERM"] != "xterm-256color" {
+		t.Errorf("Unexpected header: %+v", header)
+	}
+
+	var output string
+	last := 0.0
+	for _, event := range events {
+		if event[1] != "o" {
+			t.Errorf("Unexpected event type %v", event[1])
+		}
+		if ts := event[0].(float64); ts < last {
+			t.Errorf("Event times must not decrease: %v after %v", ts, last)
+		} else {
+			last = ts
+		}
+		output += event[2].(string)
+	}
+	if output != "hello\r\nworld\r\nerr\n" {
+		t.Errorf("Unexpected recorded output: %q", output)
+	}
+	if stdout.String() != "hello\r\nworld\r\n" || stderr.String() != "err\n" {
+		t.Errorf("Output was not passed through: %q / %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestRecordShell_RedactsPassword(t *testing.T) {
+	var cast bytes.Buffer
+	inner := &scriptedShell{output: []string{"$ ", "[sudo] password for ops: ", "# "}}
+
+	shell := RecordShell(inner, &cast, WithRecordInput(true))
+	shell.SetStdio(strings.NewReader("sudo -i\nhunter2\nid\n"), io.Discard, io.Discard)
+	if err := shell.Start(""); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if strings.Contains(cast.String(), "hunter2") {
+		t.Fatalf("Password leaked into recording: %s", cast.String())
+	}
+	if !strings.Contains(cast.String(), `"i","s"`) || !strings.Contains(cast.String(), `"i","d"`) {
+		t.Errorf("Expected non-secret input to be recorded: %s", cast.String())
+	}
+}
+
+func TestRecordShell_RedactsSplitPrompt(t *testing.T) {
+	var cast bytes.Buffer
+	shell := RecordShell(&scriptedShell{}, &cast, WithRecordInput(true)).(*recordedShell)
+
+	// The prompt arrives in two reads, the echoed newline after the password does not arm redaction again
+	shell.recordOutput("[sudo] pass")
+	shell.recordOutput("word for ops: ")
+	shell.recordInput([]byte("hunter2\r"))
+	shell.recordOutput("\r\n# ")
+	shell.recordInput([]byte("id\r"))
+
+	if strings.Contains(cast.String(), "hunter2") {
+		t.Fatalf("Password leaked into recording: %s", cast.String())
+	}
+	if !strings.Contains(cast.String(), `"i","id\r"`) {
+		t.Errorf("Expected input after the password to be recorded: %s", cast.String())
+	}
+}
+
+func TestRecordShell_InputDisabledByDefault(t *testing.T) {
+	var cast bytes.Buffer
+	inner := &scriptedShell{output: []string{"$ "}}
+
+	shell := RecordShell(inner, &cast)
+	shell.SetStdio(strings.NewReader("ls\n"), io.Discard, io.Discard)
+	shell.Start("")
+
+	_, events := parseCast(t, cast.String())
+	for _, event := range events {
+		if event[1] == "i" {
+			t.Errorf("Input recorded without WithRecordInput: %v", event)
+		}
+	}
+}
+
+func TestRecordShell_Resize(t *testing.T) {
+	var cast bytes.Buffer
+	inner := &scriptedShell{}
+	shell := RecordShell(inner, &cast)
+
+	// Before start the size goes into the header
+	shell.Resize(100, 50)
+	shell.SetStdio(nil, io.Discard, io.Discard)
+	shell.Start("")
+	shell.Resize(90, 20)
+
+	header, events := parseCast(t, cast.String())
+	if header.Width != 100 || header.Height != 50 {
+		t.Errorf("Expected header size 100x50, got %dx%d", header.Width, header.Height)
+	}
+
+	found := false
+	for _, event := range events {
+		if event[1] == "r" && event[2] == "90x20" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected resize event, got %v", events)
+	}
+	if len(inner.resizes) != 2 {
+		t.Errorf("Expected resizes to reach the wrapped shell, got %d", len(inner.resizes))
+	}
+}
+
+func TestRecordWriter_SplitUTF8(t *testing.T) {
+	var cast bytes.Buffer
+	rec := RecordShell(&scriptedShell{}, &cast).(*recordedShell)
+	rec.attach()
+
+	w := &recordWriter{w: io.Discard, rec: rec}
+	euro := []byte("€") // 3 bytes
+	w.Write([]byte{'a', euro[0]})
+	w.Write(euro[1:])
+
+	_, events := parseCast(t, cast.String())
+	var output string
+	for _, event := range events {
+		output += event[2].(string)
+	}
+	if output != "a€" {
+		t.Errorf("Expected multi-byte character to survive split writes, got %q", output)
+	}
+}
+
+// failingWriter returns an error for every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestRecordShell_WriteError(t *testing.T) {
+	shell := RecordShell(&scriptedShell{}, failingWriter{})
+	shell.SetStdio(nil, io.Discard, io.Discard)
+
+	if err := shell.Start(""); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Expected recording error, got %v", err)
+	}
+}
+
+func TestRecordShell_RemoteShell(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	var cast, stdout bytes.Buffer
+	shell := RecordShell(client.InteractiveShell(nil), &cast, WithRecordInput(true))
+	shell.SetStdio(strings.NewReader("echo recorded\n"), &stdout, io.Discard)
+	if err := shell.Start(""); err != nil {
+		t.Fatalf("Shell failed: %v", err)
+	}
+
+	// The terminal echoes the input, which can arrive in the same output event as the command's output
+	_, events := parseCast(t, cast.String())
+	var output string
+	for _, event := range events {
+		if event[1] == "o" {
+			output += event[2].(string)
+		}
+	}
+	if strings.TrimPrefix(output, "echo recorded\n") != "recorded\n" || !strings.Contains(cast.String(), `"i","echo recorded\n"`) {
+		t.Errorf("Unexpected recording: %s", cast.String())
+	}
+}
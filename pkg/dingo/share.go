@@ -0,0 +1,278 @@
+package dingo
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+)
+
+// sharedShell wraps a Shell and mirrors its terminal to observers connecting on a listener
+type sharedShell struct {
+	shell    Shell
+	listener net.Listener
+	config   ShareConfig
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	accept    sync.Once
+	mu        sync.Mutex
+	observers map[*shareObserver]struct{}
+	input     *io.PipeWriter // Merged shell input when observers may type, nil otherwise
+	closed    bool
+}
+
+// shareObserver is one connected observer with its queue of pending output
+type shareObserver struct {
+	conn  net.Conn
+	queue chan []byte
+}
+
+/*
+* Wraps a shell so its output is mirrored to every connection accepted on the listener
+* Observers see output from the moment they connect and are read-only unless WithObserverInput is set
+* The listener and all observer connections are closed when the shell ends
+* Inputs: shell (Shell) - shell to share, listener (net.Listener) - local Unix or TCP listener, opts (...ShareOption) - sharing options
+* Outputs: SharedShell interface that shares while delegating to the wrapped shell
+ */
+func ShareShell(shell Shell, listener net.Listener, opts ...ShareOption) SharedShell {
+	config := *DefaultShareConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.QueueLength < 1 {
+		config.QueueLength = 1
+	}
+
+	return &sharedShell{
+		shell:     shell,
+		listener:  listener,
+		config:    config,
+		observers: make(map[*shareObserver]struct{}),
+	}
+}
+
+/*
+* Starts the wrapped shell with shared streams
+* Inputs: command (string) - program to run instead of the login shell, "" for the shell
+* Outputs: error from the wrapped shell
+ */
+func (s *sharedShell) Start(command string) error {
+	s.attach()
+	defer s.finish()
+	return s.shell.Start(command)
+}
+
+/*
+* Starts the wrapped login shell with initial commands and shared streams
+* Inputs: commands (...string) - initial commands sent to the shell
+* Outputs: error from the wrapped shell
+ */
+func (s *sharedShell) ShellExec(commands ...string) error {
+	s.attach()
+	defer s.finish()
+	return s.shell.ShellExec(commands...)
+}
+
+/*
+* Configures the local streams of the shared shell
+* Inputs: stdin (io.Reader) - input stream, stdout (io.Writer) - output stream, stderr (io.Writer) - error stream
+* Outputs: Shell interface for method chaining
+ */
+func (s *sharedShell) SetStdio(stdin io.Reader, stdout, stderr io.Writer) Shell {
+	s.stdin = stdin
+	s.stdout = stdout
+	s.stderr = stderr
+	return s
+}
+
+/*
+* Forwards a terminal size change to the wrapped shell, observers keep their own terminal size
+* Inputs: width (int) - new width in columns, height (int) - new height in rows
+* Outputs: error if the wrapped shell fails to resize
+ */
+func (s *sharedShell) Resize(width, height int) error {
+	return s.shell.Resize(width, height)
+}
+
+/*
+* Returns the number of currently connected observers
+* Inputs: none
+* Outputs: int containing the observer count
+ */
+func (s *sharedShell) Observers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.observers)
+}
+
+/*
+* Internal helper that hands shared streams to the wrapped shell and starts accepting observers
+* Inputs: none
+* Outputs: none
+ */
+func (s *sharedShell) attach() {
+	stdin, stdout, stderr := s.stdin, s.stdout, s.stderr
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	if s.config.AllowInput {
+		// Local and observer input are merged into one pipe, local EOF ends the input
+		pr, pw := io.Pipe()
+		s.mu.Lock()
+		s.input = pw
+		s.mu.Unlock()
+		go func(local io.Reader) {
+			_, err := io.Copy(pw, local)
+			pw.CloseWithError(err)
+		}(stdin)
+		stdin = pr
+	}
+	s.shell.SetStdio(stdin, &shareWriter{w: stdout, share: s}, &shareWriter{w: stderr, share: s})
+
+	s.accept.Do(func() {
+		go s.acceptObservers()
+	})
+}
+
+/*
+* Internal helper that accepts observer connections until the listener is closed
+* Inputs: none
+* Outputs: none
+ */
+func (s *sharedShell) acceptObservers() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.addObserver(conn)
+	}
+}
+
+/*
+* Internal helper that registers an observer and starts its output and input goroutines
+* Inputs: conn (net.Conn) - accepted observer connection
+* Outputs: none
+ */
+func (s *sharedShell) addObserver(conn net.Conn) {
+	o := &shareObserver{conn: conn, queue: make(chan []byte, s.config.QueueLength)}
+	banner := "[dingo] Connected to shared session (read-only)\r\n"
+	if s.config.AllowInput {
+		banner = "[dingo] Connected to shared session (input enabled)\r\n"
+	}
+	o.queue <- []byte(banner)
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return
+	}
+	s.observers[o] = struct{}{}
+	input := s.input
+	s.mu.Unlock()
+
+	go func() {
+		for data := range o.queue {
+			if _, err := conn.Write(data); err != nil {
+				s.removeObserver(o, true)
+			}
+		}
+		conn.Close()
+	}()
+
+	go func() {
+		// Input from read-only observers is consumed and dropped
+		if input != nil {
+			io.Copy(input, conn)
+		} else {
+			io.Copy(io.Discard, conn)
+		}
+	}()
+}
+
+/*
+* Internal helper that unregisters an observer, its pending output is still delivered unless abort is set
+* Inputs: o (*shareObserver) - observer to remove, abort (bool) - close the connection immediately
+* Outputs: none
+ */
+func (s *sharedShell) removeObserver(o *shareObserver, abort bool) {
+	s.mu.Lock()
+	if _, ok := s.observers[o]; ok {
+		delete(s.observers, o)
+		close(o.queue)
+	}
+	s.mu.Unlock()
+
+	if abort {
+		o.conn.Close()
+	}
+}
+
+/*
+* Internal helper that queues output for every observer, observers that fall too far behind are disconnected
+* Inputs: data ([]byte) - output data
+* Outputs: none
+ */
+func (s *sharedShell) broadcast(data []byte) {
+	s.mu.Lock()
+	var slow []*shareObserver
+	for o := range s.observers {
+		select {
+		case o.queue <- append([]byte(nil), data...):
+		default:
+			slow = append(slow, o)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, o := range slow {
+		s.removeObserver(o, true)
+	}
+}
+
+/*
+* Internal helper that stops accepting observers and disconnects the remaining ones once the shell has ended
+* Inputs: none
+* Outputs: none
+ */
+func (s *sharedShell) finish() {
+	s.mu.Lock()
+	s.closed = true
+	observers := make([]*shareObserver, 0, len(s.observers))
+	for o := range s.observers {
+		observers = append(observers, o)
+	}
+	s.mu.Unlock()
+
+	s.listener.Close()
+	for _, o := range observers {
+		s.removeObserver(o, false)
+	}
+}
+
+// shareWriter copies output to the real writer and to the observers
+type shareWriter struct {
+	w     io.Writer
+	share *sharedShell
+}
+
+/*
+* Writes data to the real writer and queues it for the observers
+* Inputs: p ([]byte) - output data
+* Outputs: int containing bytes written, error if the real writer fails
+ */
+func (sw *shareWriter) Write(p []byte) (int, error) {
+	sw.share.broadcast(p)
+	return sw.w.Write(p)
+}
@@ -0,0 +1,150 @@
+package dingo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoShell is a Shell that answers each input line until its input ends, for sharing tests
+type echoShell struct {
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (s *echoShell) Start(command string) error {
+	scanner := bufio.NewScanner(s.stdin)
+	for scanner.Scan() {
+		fmt.Fprintf(s.stdout, "got: %s\n", scanner.Text())
+	}
+	return nil
+}
+
+func (s *echoShell) ShellExec(commands ...string) error {
+	return s.Start("")
+}
+
+func (s *echoShell) SetStdio(stdin io.Reader, stdout, stderr io.Writer) Shell {
+	s.stdin, s.stdout = stdin, stdout
+	return s
+}
+
+func (s *echoShell) Resize(width, height int) error {
+	return nil
+}
+
+/*
+* Test helper that starts a shared echo shell and connects one observer to it
+* Inputs: t (*testing.T) - test context, opts (...ShareOption) - sharing options
+* Outputs: SharedShell, local input writer, local output buffer, observer reader and connection, channel receiving the shell result
+ */
+func startSharedEcho(t *testing.T, opts ...ShareOption) (SharedShell, *io.PipeWriter, *bytes.Buffer, *bufio.Reader, net.Conn, chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	stdin, input := io.Pipe()
+	var output bytes.Buffer
+	shared := ShareShell(&echoShell{}, listener, opts...)
+	shared.SetStdio(stdin, &output, &output)
+
+	done := make(chan error, 1)
+	go func() { done <- shared.Start("") }()
+
+	var conn net.Conn
+	deadline := time.Now().Add(5 * time.Second)
+	for conn == nil {
+		if conn, err = net.Dial("tcp", listener.Addr().String()); err != nil && time.Now().After(deadline) {
+			t.Fatalf("Dial failed: %v", err)
+		}
+	}
+	t.Cleanup(func() { conn.Close() })
+	for shared.Observers() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Observer was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return shared, input, &output, bufio.NewReader(conn), conn, done
+}
+
+func TestShareShell_ReadOnlyObserver(t *testing.T) {
+	shared, input, output, observer, conn, done := startSharedEcho(t)
+
+	if banner, _ := observer.ReadString('\n'); !strings.Contains(banner, "read-only") {
+		t.Errorf("Unexpected banner %q", banner)
+	}
+
+	// Observer input is discarded
+	conn.Write([]byte("intruder\n"))
+	io.WriteString(input, "hello\n")
+	if line, err := observer.ReadString('\n'); err != nil || line != "got: hello\n" {
+		t.Errorf("Unexpected mirrored output %q, %v", line, err)
+	}
+
+	input.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	// The connection may be reset rather than closed since the discarded input was never consumed
+	if rest, _ := io.ReadAll(observer); len(rest) != 0 {
+		t.Errorf("Expected the connection to close after the shell ended, got %q", rest)
+	}
+	if output.String() != "got: hello\n" {
+		t.Errorf("Unexpected local output %q", output.String())
+	}
+	if shared.Observers() != 0 {
+		t.Errorf("Expected no observers, got %d", shared.Observers())
+	}
+}
+
+func TestShareShell_ObserverInput(t *testing.T) {
+	_, input, output, observer, conn, done := startSharedEcho(t, WithObserverInput(true))
+
+	if banner, _ := observer.ReadString('\n'); !strings.Contains(banner, "input enabled") {
+		t.Errorf("Unexpected banner %q", banner)
+	}
+
+	conn.Write([]byte("from observer\n"))
+	if line, err := observer.ReadString('\n'); err != nil || line != "got: from observer\n" {
+		t.Errorf("Unexpected mirrored output %q, %v", line, err)
+	}
+	io.WriteString(input, "from local\n")
+	if line, err := observer.ReadString('\n'); err != nil || line != "got: from local\n" {
+		t.Errorf("Unexpected mirrored output %q, %v", line, err)
+	}
+
+	input.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if output.String() != "got: from observer\ngot: from local\n" {
+		t.Errorf("Unexpected local output %q", output.String())
+	}
+}
+
+func TestShareShell_SlowObserverDropped(t *testing.T) {
+	shared, input, _, _, _, done := startSharedEcho(t, WithObserverQueue(1))
+
+	// The observer never reads, so its queue overflows and it is disconnected
+	for i := 0; i < 100000 && shared.Observers() > 0; i++ {
+		io.WriteString(input, strings.Repeat("x", 1024)+"\n")
+	}
+	if shared.Observers() != 0 {
+		t.Error("Slow observer was not disconnected")
+	}
+
+	input.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package dingo
 import (
 	"io"
 	"os"
+	"sync"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -13,48 +14,153 @@ type remoteShell struct {
 	shellType      ShellType
 	requestPty     bool
 	terminalConfig *TerminalConfig
+	forwardAgent   bool
 
 	stdin  io.Reader
 	stdout io.Writer
 	stderr io.Writer
+
+	mu      sync.Mutex
+	session *ssh.Session // Active session, nil when the shell is not running
 }
 
 /*
 * Initiates the shell session, sets up streams, requests PTY if needed, and waits for completion
-* Inputs: command - A command to run interactively instead of the shell, if command is "" the regular shell is run
-* Outputs: error if session creation, PTY request, or shell startup fails, nil on successful completion
+* Inputs: command - A command to run as the interactive program instead of the shell, if command is "" the regular shell is run
+* Outputs: error if session creation, PTY request, or startup fails, the remote exit status error, nil on successful completion
  */
 func (rs *remoteShell) Start(command string) error {
-	session, err := rs.client.NewSession()
+	session, err := rs.newSession()
 	if err != nil {
 		return err
 	}
 	defer session.Close()
+	defer rs.setSession(nil)
 
 	// Set up input/output streams
 	rs.setupStreams(session)
 
-	// Request PTY if needed (for interactive shells)
-	if rs.requestPty {
-		if err := rs.requestPseudoTerminal(session); err != nil {
-			return err
-		}
+	// Run the command in place of the shell, or start the login shell
+	if command != "" {
+		err = session.Start(command)
+	} else {
+		err = session.Shell()
+	}
+	if err != nil {
+		return err
 	}
 
-	// If command is provided start the command
-	if command != "" {
-		session.Run(command)
+	// Wait for the session to complete
+	return session.Wait()
+}
+
+/*
+* Starts the login shell and types the given commands into it before handing over the configured stdin
+* Inputs: commands (...string) - initial commands sent to the shell, one per line
+* Outputs: error if session creation, PTY request, or shell startup fails, the remote exit status error, nil on successful completion
+ */
+func (rs *remoteShell) ShellExec(commands ...string) error {
+	session, err := rs.newSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	defer rs.setSession(nil)
+
+	// Capture the configured streams, then take over stdin to inject the commands
+	rs.setupStreams(session)
+	stdin := session.Stdin
+	session.Stdin = nil
+
+	stdinPipe, err := session.StdinPipe()
+	if err != nil {
+		return err
 	}
 
-	// Start the shell
 	if err := session.Shell(); err != nil {
 		return err
 	}
 
-	// Wait for the session to complete
+	go func() {
+		defer stdinPipe.Close()
+		for _, command := range commands {
+			if _, err := io.WriteString(stdinPipe, command+"\n"); err != nil {
+				return
+			}
+		}
+		io.Copy(stdinPipe, stdin)
+	}()
+
 	return session.Wait()
 }
 
+/*
+* Changes the terminal size, notifying the remote PTY if the shell is running
+* Inputs: width (int) - new width in columns, height (int) - new height in rows
+* Outputs: error if the window-change request fails, nil on success
+ */
+func (rs *remoteShell) Resize(width, height int) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	// Copy the configuration so shared defaults are never modified
+	config := *DefaultTerminalConfig
+	if rs.terminalConfig != nil {
+		config = *rs.terminalConfig
+	}
+	config.Width = width
+	config.Height = height
+	rs.terminalConfig = &config
+
+	if rs.session == nil || !rs.requestPty {
+		return nil
+	}
+	return rs.session.WindowChange(height, width)
+}
+
+/*
+* Internal helper that records the running session so Resize can reach it
+* Inputs: session (*ssh.Session) - active session or nil when the shell has finished
+* Outputs: none
+ */
+func (rs *remoteShell) setSession(session *ssh.Session) {
+	rs.mu.Lock()
+	rs.session = session
+	rs.mu.Unlock()
+}
+
+/*
+* Internal helper that opens a session, requests a PTY for interactive shells and records it as the active session
+* Inputs: none (uses internal client and terminal configuration)
+* Outputs: *ssh.Session ready to start, error if session creation or PTY request fails
+ */
+func (rs *remoteShell) newSession() (*ssh.Session, error) {
+	session, err := rs.client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	if rs.forwardAgent {
+		if err := requestAgentForwarding(session); err != nil {
+			session.Close()
+			return nil, err
+		}
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	// Request PTY if needed (for interactive shells)
+	if rs.requestPty {
+		if err := rs.requestPseudoTerminal(session); err != nil {
+			session.Close()
+			return nil, err
+		}
+	}
+
+	rs.session = session
+	return session, nil
+}
+
 /*
 * Configures custom input/output streams for the shell session
 * Inputs: stdin (io.Reader) - input stream, stdout (io.Writer) - output stream, stderr (io.Writer) - error stream
@@ -101,7 +207,15 @@ func (rs *remoteShell) setupStreams(session *ssh.Session) {
 * Outputs: error if PTY request fails, nil on success
  */
 func (rs *remoteShell) requestPseudoTerminal(session *ssh.Session) error {
-	tc := rs.terminalConfig
+	return requestPty(session, rs.terminalConfig)
+}
+
+/*
+* Internal helper that requests a pseudo-terminal with the given configuration on a session
+* Inputs: session (*ssh.Session) - SSH session to request PTY for, tc (*TerminalConfig) - terminal configuration or nil for defaults
+* Outputs: error if PTY request fails, nil on success
+ */
+func requestPty(session *ssh.Session, tc *TerminalConfig) error {
 	if tc == nil {
 		tc = DefaultTerminalConfig
 	}
@@ -127,6 +241,7 @@ func (c *client) ShellWithCustomTerminal(term string, width, height int, modes s
 		shellType:      InteractiveShell,
 		requestPty:     true,
 		terminalConfig: config,
+		forwardAgent:   c.agent,
 	}
 }
 
@@ -3,6 +3,8 @@ package dingo
 import (
 	"bytes"
 	"errors"
+	"io"
+	"strings"
 	"testing"
 
 	"golang.org/x/crypto/ssh"
@@ -437,3 +439,132 @@ func TestRemoteShell_Start_SessionCreationFailure(t *testing.T) {
 		t.Error("Expected error when SSH client is nil, but no panic occurred")
 	}
 }
+
+func TestRemoteShell_Start_Command(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	var stdout, stderr bytes.Buffer
+	shell := client.InteractiveShell(&TerminalConfig{Term: "xterm-256color", Width: 120, Height: 30, Modes: ssh.TerminalModes{}})
+	shell.SetStdio(strings.NewReader("echo not-a-shell\n"), &stdout, &stderr)
+
+	// The command replaces the shell: it runs on the PTY and stdin is not interpreted as shell input
+	err := shell.Start(`echo "$TERM $DINGO_TEST_PTY"; exit 5`)
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 5 {
+		t.Fatalf("Expected exit status 5 from the command, got %v", err)
+	}
+	if got := stdout.String(); got != "xterm-256color 120x30\n" {
+		t.Errorf("Unexpected output: %q", got)
+	}
+}
+
+func TestRemoteShell_Start_LoginShell(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	var stdout bytes.Buffer
+	shell := client.Shell().SetStdio(strings.NewReader("echo from-shell\n"), &stdout, nil)
+
+	if err := shell.Start(""); err != nil {
+		t.Fatalf("Shell failed: %v", err)
+	}
+	if got := stdout.String(); got != "from-shell\n" {
+		t.Errorf("Unexpected output: %q", got)
+	}
+}
+
+func TestRemoteShell_ShellExec(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	var stdout bytes.Buffer
+	shell := client.Shell().SetStdio(strings.NewReader("echo three\nexit 2\n"), &stdout, nil)
+
+	err := shell.ShellExec("echo one", "X=two; echo $X")
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 2 {
+		t.Fatalf("Expected exit status 2 from the shell, got %v", err)
+	}
+	if got := stdout.String(); got != "one\ntwo\nthree\n" {
+		t.Errorf("Unexpected output: %q", got)
+	}
+}
+
+func TestRemoteShell_ShellExec_NilClient(t *testing.T) {
+	rs := createTestRemoteShell(NonInteractiveShell, false, nil)
+
+	// Should panic when SSH client is nil
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("Expected panic when SSH client is nil")
+		}
+	}()
+
+	rs.ShellExec("echo hi")
+}
+
+func TestRemoteShell_Resize_BeforeStart(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	var stdout bytes.Buffer
+	shell := client.InteractiveShell(nil).SetStdio(strings.NewReader(""), &stdout, nil)
+	if err := shell.Resize(100, 40); err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+
+	if err := shell.Start(`echo "$DINGO_TEST_PTY"`); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if got := stdout.String(); got != "100x40\n" {
+		t.Errorf("Expected PTY of 100x40, got %q", got)
+	}
+	if DefaultTerminalConfig.Width != 80 || DefaultTerminalConfig.Height != 40 {
+		t.Error("Resize must not modify DefaultTerminalConfig")
+	}
+}
+
+func TestRemoteShell_Resize_WhileRunning(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	stdinReader, stdinWriter := io.Pipe()
+	var stdout, stderr syncBuffer
+	shell := client.InteractiveShell(nil).SetStdio(stdinReader, &stdout, &stderr)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- shell.Start("echo ready; read line; echo done") }()
+
+	waitForOutput(t, &stdout, "ready")
+	if err := shell.Resize(132, 43); err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+	waitForOutput(t, &stderr, "window-change 132x43")
+
+	stdinWriter.Write([]byte("\n"))
+	if err := <-errCh; err != nil {
+		t.Fatalf("Shell failed: %v", err)
+	}
+	stdinWriter.Close()
+}
+
+func TestRemoteShell_Resize_NotRunning(t *testing.T) {
+	rs := createTestRemoteShell(InteractiveShell, true, DefaultTerminalConfig)
+
+	if err := rs.Resize(120, 50); err != nil {
+		t.Fatalf("Resize without session should not fail: %v", err)
+	}
+	if rs.terminalConfig == DefaultTerminalConfig || rs.terminalConfig.Width != 120 || rs.terminalConfig.Height != 50 {
+		t.Errorf("Expected a copied config of 120x50, got %+v", rs.terminalConfig)
+	}
+}
+
+func TestRunInLocalTerminal_NotATerminal(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	// go test does not attach a terminal to stdin, so the shell runs without raw mode
+	var stdout bytes.Buffer
+	shell := client.InteractiveShell(nil).SetStdio(strings.NewReader(""), &stdout, nil)
+	if err := RunInLocalTerminal(shell, "echo plain"); err != nil {
+		t.Fatalf("RunInLocalTerminal failed: %v", err)
+	}
+	if got := stdout.String(); got != "plain\n" {
+		t.Errorf("Unexpected output: %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+package dingo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// signalNumbers maps forwarded SSH signals to their conventional POSIX numbers
+var signalNumbers = map[ssh.Signal]int{
+	ssh.SIGHUP:  1,
+	ssh.SIGINT:  2,
+	ssh.SIGQUIT: 3,
+	ssh.SIGKILL: 9,
+	ssh.SIGTERM: 15,
+}
+
+// SignalError reports that a remote command was cut off because it did not exit within the grace period after a forwarded signal
+type SignalError struct {
+	Signal ssh.Signal
+}
+
+/*
+* Returns a description of the unanswered signal
+* Inputs: none
+* Outputs: string containing the error message
+ */
+func (e *SignalError) Error() string {
+	return fmt.Sprintf("remote command did not exit after SIG%s, session closed", e.Signal)
+}
+
+/*
+* Returns the shell-style exit status for a process terminated by the signal (128 + signal number)
+* Inputs: none
+* Outputs: int containing the exit status
+ */
+func (e *SignalError) ExitStatus() int {
+	return 128 + signalNumbers[e.Signal]
+}
+
+/*
+* Forwards local signals to the remote process while the command runs
+* If the process has not exited after the grace period, or a second signal arrives, the session is closed
+* Inputs: signals (<-chan os.Signal) - channel registered with signal.Notify, grace (time.Duration) - time to wait before closing the session
+* Outputs: CommandExecutor interface for method chaining
+ */
+func (rs *remoteScript) ForwardSignals(signals <-chan os.Signal, grace time.Duration) CommandExecutor {
+	rs.signals = signals
+	rs.signalGrace = grace
+	return rs
+}
+
+/*
+* Internal helper that waits for a started session, forwarding signals if ForwardSignals was configured
+* Inputs: session (*ssh.Session) - started SSH session
+* Outputs: error from session.Wait, or *SignalError if the session had to be closed after a signal
+ */
+func (rs *remoteScript) wait(session *ssh.Session) error {
+	if rs.signals == nil {
+		return session.Wait()
+	}
+
+	done := make(chan struct{})
+	result := make(chan *SignalError, 1)
+	go func() {
+		result <- forwardSignals(session, rs.signals, rs.signalGrace, done)
+	}()
+
+	err := session.Wait()
+	close(done)
+
+	// Prefer the remote exit status if the process managed to report one
+	var exitErr *ssh.ExitError
+	if sigErr := <-result; sigErr != nil && !errors.As(err, &exitErr) {
+		return sigErr
+	}
+	return err
+}
+
+/*
+* Internal helper that relays signals to a session until done is closed
+* Inputs: session (*ssh.Session) - running SSH session, signals (<-chan os.Signal) - local signals, grace (time.Duration) - time to wait before closing the session, done (<-chan struct{}) - closed when the session has finished
+* Outputs: *SignalError if the session was closed, nil if it finished on its own
+ */
+func forwardSignals(session *ssh.Session, signals <-chan os.Signal, grace time.Duration, done <-chan struct{}) *SignalError {
+	var (
+		timeout   <-chan time.Time
+		forwarded ssh.Signal
+	)
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case sig := <-signals:
+			if timeout != nil {
+				session.Close() // Second signal, stop waiting
+				return &SignalError{Signal: forwarded}
+			}
+			forwarded = sshSignal(sig)
+			session.Signal(forwarded)
+			timeout = time.After(grace)
+		case <-timeout:
+			session.Close()
+			return &SignalError{Signal: forwarded}
+		}
+	}
+}
+
+/*
+* Internal helper that converts a local signal into its SSH protocol name
+* Inputs: sig (os.Signal) - signal received by the local process
+* Outputs: ssh.Signal - matching SSH signal, SIGTERM for signals without an equivalent
+ */
+func sshSignal(sig os.Signal) ssh.Signal {
+	switch sig {
+	case os.Interrupt:
+		return ssh.SIGINT
+	case syscall.SIGHUP:
+		return ssh.SIGHUP
+	case syscall.SIGQUIT:
+		return ssh.SIGQUIT
+	case os.Kill:
+		return ssh.SIGKILL
+	default:
+		return ssh.SIGTERM
+	}
+}
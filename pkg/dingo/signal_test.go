@@ -0,0 +1,152 @@
+package dingo
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+/*
+* Test helper that waits until the remote command has printed the given marker
+* Inputs: t (*testing.T) - test context, buf (*syncBuffer) - captured output, marker (string) - text to wait for
+* Outputs: none (fails the test on timeout)
+ */
+func waitForOutput(t *testing.T, buf *syncBuffer, marker string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(buf.String(), marker) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %q, output: %q", marker, buf.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForwardSignals_RemoteHandlesSignal(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	signals := make(chan os.Signal, 1)
+	var stdout syncBuffer
+	cmd := client.Command(`trap 'echo caught; exit 7' TERM; echo ready; while true; do sleep 0.05; done`).
+		SetStdio(&stdout, nil).
+		ForwardSignals(signals, 5*time.Second)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- cmd.Run() }()
+
+	waitForOutput(t, &stdout, "ready")
+	signals <- syscall.SIGTERM
+
+	err := <-errCh
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 7 {
+		t.Fatalf("Expected remote exit status 7, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "caught") {
+		t.Errorf("Expected remote trap output, got %q", stdout.String())
+	}
+}
+
+func TestForwardSignals_RemoteKilledBySignal(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	signals := make(chan os.Signal, 1)
+	var stdout syncBuffer
+	cmd := client.Command(`echo ready; while true; do sleep 0.05; done`).
+		SetStdio(&stdout, nil).
+		ForwardSignals(signals, 5*time.Second)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- cmd.Run() }()
+
+	waitForOutput(t, &stdout, "ready")
+	signals <- os.Interrupt
+
+	err := <-errCh
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.Signal() != string(ssh.SIGINT) || exitErr.ExitStatus() != 130 {
+		t.Fatalf("Expected exit by SIGINT with status 130, got %v", err)
+	}
+}
+
+func TestForwardSignals_GracePeriodExpires(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	signals := make(chan os.Signal, 1)
+	var stdout syncBuffer
+	cmd := client.Command(`trap '' TERM; echo ready; sleep 2`).
+		SetStdio(&stdout, nil).
+		ForwardSignals(signals, 100*time.Millisecond)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- cmd.Run() }()
+
+	waitForOutput(t, &stdout, "ready")
+	start := time.Now()
+	signals <- syscall.SIGTERM
+
+	err := <-errCh
+	var sigErr *SignalError
+	if !errors.As(err, &sigErr) || sigErr.Signal != ssh.SIGTERM {
+		t.Fatalf("Expected SignalError for SIGTERM, got %v", err)
+	}
+	if sigErr.ExitStatus() != 143 {
+		t.Errorf("Expected exit status 143, got %d", sigErr.ExitStatus())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Session should be closed after the grace period, took %v", elapsed)
+	}
+}
+
+func TestForwardSignals_NoSignal(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	output, err := client.Command("echo done").ForwardSignals(make(chan os.Signal), time.Second).Output()
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if string(output) != "done\n" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestSSHSignal(t *testing.T) {
+	tests := map[os.Signal]ssh.Signal{
+		os.Interrupt:    ssh.SIGINT,
+		syscall.SIGTERM: ssh.SIGTERM,
+		syscall.SIGHUP:  ssh.SIGHUP,
+		syscall.SIGQUIT: ssh.SIGQUIT,
+		os.Kill:         ssh.SIGKILL,
+	}
+
+	for sig, expected := range tests {
+		if got := sshSignal(sig); got != expected {
+			t.Errorf("sshSignal(%v) = %s, expected %s", sig, got, expected)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes and reads
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
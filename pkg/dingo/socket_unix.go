@@ -0,0 +1,64 @@
+//go:build !windows
+
+package dingo
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// privateUnixListener is a Unix socket listener that removes its socket path when closed
+type privateUnixListener struct {
+	net.Listener
+	path string
+}
+
+/*
+* Listens on a Unix socket that only the current user can connect to
+* The socket is created in a new directory only the current user can enter, restricted to 0600 and then linked
+* to path, so it is never reachable with looser permissions. Like bind, it fails if path already exists
+* Inputs: path (string) - socket path
+* Outputs: net.Listener that removes the socket when closed, error if the socket cannot be created
+ */
+func ListenUnix(path string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".dingo-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	private := filepath.Join(dir, "s")
+	listener, err := net.Listen("unix", private)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(private, 0600); err == nil {
+		err = os.Link(private, path)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &privateUnixListener{Listener: listener, path: path}, nil
+}
+
+/*
+* Returns the socket path the listener was linked to
+* Inputs: none
+* Outputs: net.Addr of the socket
+ */
+func (l *privateUnixListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+/*
+* Stops listening and removes the socket path
+* Inputs: none
+* Outputs: error if the listener cannot be closed
+ */
+func (l *privateUnixListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package dingo
+
+import "net"
+
+/*
+* Listens on a Unix socket, Windows ignores Unix permissions and the socket gets the access control list of its directory
+* Inputs: path (string) - socket path
+* Outputs: net.Listener that removes the socket when closed, error if the socket cannot be created
+ */
+func ListenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,184 @@
+package dingo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SOCKS5 protocol constants, see RFC 1928
+const (
+	socksVersion        = 5
+	socksNoAuth         = 0
+	socksNoAcceptable   = 0xff
+	socksConnect        = 1
+	socksAddrIPv4       = 1
+	socksAddrDomain     = 3
+	socksAddrIPv6       = 4
+	socksSucceeded      = 0
+	socksGeneralFailure = 1
+	socksNotAllowed     = 2
+	socksRefused        = 5
+	socksBadCommand     = 7
+	socksBadAddrType    = 8
+)
+
+// socksHandshakeTimeout limits how long a client may take to send its SOCKS request
+const socksHandshakeTimeout = 30 * time.Second
+
+/*
+* Starts a SOCKS5 proxy on a local address whose CONNECT requests are dialed from the remote host, like ssh -D
+* Domain names are resolved on the remote host, only unauthenticated CONNECT requests are supported
+* Inputs: localAddr (string) - local host:port or Unix socket path to listen on, opts (...ForwardOption) - forwarding options
+* Outputs: Forward interface for the proxy, error if the local address cannot be listened on
+ */
+func (c *client) ForwardDynamic(localAddr string, opts ...ForwardOption) (Forward, error) {
+	listener, err := listenLocal(localAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return newForwarder(listener, "SOCKS proxy", func(conn net.Conn) (net.Conn, error) {
+		return socksConnectRequest(conn, c.sshClient.Dial)
+	}, newForwardConfig(opts)), nil
+}
+
+/*
+* Connects to an address from the remote host, for use as a dialer by Go programs
+* Inputs: ctx (context.Context) - cancels the dial, network (string) - "tcp", "tcp4", "tcp6" or "unix", addr (string) - host:port resolved on the remote host, or a remote socket path
+* Outputs: net.Conn tunnelled through the SSH connection, error if the connection fails
+ */
+func (c *client) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return c.sshClient.DialContext(ctx, network, addr)
+}
+
+/*
+* Returns an HTTP transport whose connections are made from the remote host, no local listener is needed
+* Inputs: none
+* Outputs: *http.Transport usable as the RoundTripper of an http.Client
+ */
+func (c *client) HTTPTransport() *http.Transport {
+	return &http.Transport{
+		DialContext:           c.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+/*
+* Internal helper that runs the SOCKS5 handshake on an accepted connection and dials the requested address
+* Inputs: conn (net.Conn) - accepted client connection, dial (func) - dials the requested address
+* Outputs: net.Conn connected to the requested address, error if the handshake or the dial fails
+ */
+func socksConnectRequest(conn net.Conn, dial func(network, addr string) (net.Conn, error)) (net.Conn, error) {
+	conn.SetDeadline(time.Now().Add(socksHandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	// Greeting: version, number of methods, methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, fmt.Errorf("SOCKS greeting: %w", err)
+	}
+	if header[0] != socksVersion {
+		return nil, fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, fmt.Errorf("SOCKS greeting: %w", err)
+	}
+	method := byte(socksNoAcceptable)
+	for _, m := range methods {
+		if m == socksNoAuth {
+			method = socksNoAuth
+		}
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return nil, err
+	}
+	if method == socksNoAcceptable {
+		return nil, errors.New("SOCKS client does not offer unauthenticated access")
+	}
+
+	// Request: version, command, reserved, address type, address, port
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return nil, fmt.Errorf("SOCKS request: %w", err)
+	}
+	if request[1] != socksConnect {
+		socksReply(conn, socksBadCommand)
+		return nil, fmt.Errorf("unsupported SOCKS command %d", request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksAddrIPv4, socksAddrIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if request[3] == socksAddrIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, fmt.Errorf("SOCKS request: %w", err)
+		}
+		host = ip.String()
+	case socksAddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return nil, fmt.Errorf("SOCKS request: %w", err)
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return nil, fmt.Errorf("SOCKS request: %w", err)
+		}
+		host = string(domain)
+	default:
+		socksReply(conn, socksBadAddrType)
+		return nil, fmt.Errorf("unsupported SOCKS address type %d", request[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return nil, fmt.Errorf("SOCKS request: %w", err)
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+	target, err := dial("tcp", addr)
+	if err != nil {
+		code := byte(socksGeneralFailure)
+		var openErr *ssh.OpenChannelError
+		if errors.As(err, &openErr) {
+			switch openErr.Reason {
+			case ssh.Prohibited:
+				code = socksNotAllowed
+			case ssh.ConnectionFailed:
+				code = socksRefused
+			}
+		}
+		socksReply(conn, code)
+		return nil, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	if err := socksReply(conn, socksSucceeded); err != nil {
+		target.Close()
+		return nil, err
+	}
+	return target, nil
+}
+
+/*
+* Internal helper that sends a SOCKS5 reply, the bound address is not meaningful through SSH and is sent as 0.0.0.0:0
+* Inputs: conn (net.Conn) - client connection, code (byte) - reply code
+* Outputs: error if writing fails
+ */
+func socksReply(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{socksVersion, code, 0, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
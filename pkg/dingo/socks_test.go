@@ -0,0 +1,138 @@
+package dingo
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+/*
+* Test helper that opens a SOCKS5 connection and sends a request
+* Inputs: t (*testing.T) - test context, proxy (string) - proxy address, command (byte) - SOCKS command, host (string) - IP address or domain name, port (int) - target port
+* Outputs: net.Conn to the proxy positioned after the reply, byte containing the reply code
+ */
+func socksRequest(t *testing.T, proxy string, command byte, host string, port int) (net.Conn, byte) {
+	t.Helper()
+	conn, err := net.Dial("tcp", proxy)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	conn.Write([]byte{5, 1, 0})
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(conn, greeting); err != nil || greeting[0] != 5 || greeting[1] != 0 {
+		t.Fatalf("Unexpected greeting %v, %v", greeting, err)
+	}
+
+	request := []byte{5, command, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		request = append(append(request, 3, byte(len(host))), host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(append(request, 1), ip4...)
+	} else {
+		request = append(append(request, 4), ip...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(port))
+	conn.Write(request)
+
+	reply := make([]byte, 10)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatalf("Reading reply failed: %v", err)
+	}
+	return conn, reply[1]
+}
+
+func TestForwardDynamic(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+	_, port, _ := net.SplitHostPort(startLineEchoServer(t))
+	targetPort, _ := strconv.Atoi(port)
+
+	proxy, err := client.ForwardDynamic("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ForwardDynamic failed: %v", err)
+	}
+	defer proxy.Close()
+
+	for _, host := range []string{"127.0.0.1", "localhost"} {
+		conn, code := socksRequest(t, proxy.Addr().String(), 1, host, targetPort)
+		if code != 0 {
+			t.Fatalf("Host %s: expected success, got reply %d", host, code)
+		}
+		fmt.Fprintf(conn, "via %s\n", host)
+		if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != "echo: via "+host+"\n" {
+			t.Errorf("Host %s: unexpected reply %q, %v", host, line, err)
+		}
+	}
+}
+
+func TestForwardDynamic_Errors(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	var reported []error
+	errs := make(chan error, 4)
+	proxy, err := client.ForwardDynamic("127.0.0.1:0", WithForwardErrorHandler(func(err error) { errs <- err }))
+	if err != nil {
+		t.Fatalf("ForwardDynamic failed: %v", err)
+	}
+	defer proxy.Close()
+
+	// Nothing listens on a freed port
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	if _, code := socksRequest(t, proxy.Addr().String(), 1, "127.0.0.1", closedPort); code != 5 {
+		t.Errorf("Expected connection refused reply, got %d", code)
+	}
+	if _, code := socksRequest(t, proxy.Addr().String(), 2, "127.0.0.1", closedPort); code != 7 {
+		t.Errorf("Expected command not supported reply, got %d", code)
+	}
+
+	for len(reported) < 2 {
+		select {
+		case err := <-errs:
+			reported = append(reported, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected 2 reported errors, got %v", reported)
+		}
+	}
+}
+
+func TestHTTPTransport(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "internal api")
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{Transport: client.HTTPTransport(), Timeout: 5 * time.Second}
+	resp, err := httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "internal api" {
+		t.Errorf("Unexpected body %q", body)
+	}
+}
+
+func TestDialContext_Cancelled(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.DialContext(ctx, "tcp", "127.0.0.1:80"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package dingo
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Privilege escalation errors, wrapped in a *SudoError carrying the remote message
+var (
+	ErrSudoWrongPassword     = errors.New("privilege escalation failed: incorrect password")
+	ErrSudoNotAllowed        = errors.New("privilege escalation failed: user is not allowed to run commands as the target user")
+	ErrSudoPasswordRequired  = errors.New("privilege escalation failed: password required but no credential provider configured")
+	ErrSudoUnsupportedMethod = errors.New("unsupported privilege escalation method")
+)
+
+// SudoError describes a failed privilege escalation together with the message printed by the remote tool
+type SudoError struct {
+	Err    error
+	Output string
+}
+
+/*
+* Returns the escalation failure with the remote message appended when available
+* Inputs: none
+* Outputs: string containing the error message
+ */
+func (e *SudoError) Error() string {
+	if e.Output == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v: %s", e.Err, e.Output)
+}
+
+/*
+* Returns the underlying sentinel error so callers can use errors.Is
+* Inputs: none
+* Outputs: error - one of the ErrSudo* values
+ */
+func (e *SudoError) Unwrap() error {
+	return e.Err
+}
+
+// StaticPassword is a CredentialProvider that always returns the same password
+type StaticPassword string
+
+/*
+* Returns the fixed password
+* Inputs: none
+* Outputs: string containing the password, nil error
+ */
+func (p StaticPassword) Password() (string, error) {
+	return string(p), nil
+}
+
+// PasswordFunc adapts a function to the CredentialProvider interface, e.g. to prompt the local user
+type PasswordFunc func() (string, error)
+
+/*
+* Calls the wrapped function to obtain the password
+* Inputs: none
+* Outputs: string containing the password, error if the function fails
+ */
+func (f PasswordFunc) Password() (string, error) {
+	return f()
+}
+
+/*
+* Internal helper that runs a command through the configured escalation tool and answers its password prompt
+* The prompt, the password and any escalation messages are kept out of the captured output
+* Inputs: session (*ssh.Session) - new SSH session, command (string) - remote command, "" for a shell reading stdin, stdin (io.Reader) - input for the command or nil
+* Outputs: error if escalation or execution fails, nil on success
+ */
+func (rs *remoteScript) runElevated(session *ssh.Session, command string, stdin io.Reader) error {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+	readyMarker := "DINGO-READY-" + hex.EncodeToString(token)
+	promptMarker := "DINGO-PROMPT-" + hex.EncodeToString(token) + ":"
+
+	if command == "" {
+		command = "sh -s"
+	}
+	elevated, err := escalationCommand(rs.sudoConfig, command, readyMarker, promptMarker)
+	if err != nil {
+		return err
+	}
+
+	stdinPipe, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	// su and doas read the password from the terminal and a PTY merges stderr into stdout,
+	// so in those cases the prompt arrives on stdout and echo must be disabled
+	usePty := rs.sudoConfig.Method != EscalateSudo || rs.terminalConfig != nil
+	ew := &escalationWriter{
+		readyMarker:  readyMarker,
+		promptMarker: promptMarker,
+		credentials:  rs.sudoConfig.Credentials,
+		stdin:        stdinPipe,
+	}
+	ew.onReady = func() {
+		go func() {
+			last := byte('\n')
+			if stdin != nil {
+				lw := &lastByteWriter{w: stdinPipe, last: last}
+				io.Copy(lw, stdin)
+				last = lw.last
+			}
+			if usePty {
+				// A PTY only reports end of input through the EOF character
+				if last != '\n' {
+					stdinPipe.Write([]byte{4})
+				}
+				stdinPipe.Write([]byte{4})
+			}
+			stdinPipe.Close()
+		}()
+	}
+
+	if usePty {
+		if err := requestPty(session, noEchoTerminal(rs.terminalConfig)); err != nil {
+			return err
+		}
+		ew.out = rs.stdout
+		session.Stdout = ew
+		session.Stderr = rs.stderr
+	} else {
+		ew.out = rs.stderr
+		session.Stdout = rs.stdout
+		session.Stderr = ew
+	}
+
+	if err := session.Start(elevated); err != nil {
+		return err
+	}
+	return ew.result(rs.wait(session))
+}
+
+/*
+* Internal helper that copies a terminal configuration with echo disabled so the password is not echoed back
+* Inputs: tc (*TerminalConfig) - terminal configuration or nil for defaults
+* Outputs: *TerminalConfig containing the copy with ECHO off
+ */
+func noEchoTerminal(tc *TerminalConfig) *TerminalConfig {
+	if tc == nil {
+		tc = DefaultTerminalConfig
+	}
+
+	config := *tc
+	config.Modes = ssh.TerminalModes{}
+	for mode, value := range tc.Modes {
+		config.Modes[mode] = value
+	}
+	config.Modes[ssh.ECHO] = 0
+	return &config
+}
+
+/*
+* Internal helper that wraps a command in the escalation tool invocation
+* The wrapped command prints readyMarker once privileges are granted
+* Inputs: config (*SudoConfig) - escalation configuration, command (string) - command to elevate, readyMarker (string) - success marker, promptMarker (string) - sudo password prompt
+* Outputs: string containing the remote command line, error if the method is unsupported
+ */
+func escalationCommand(config *SudoConfig, command, readyMarker, promptMarker string) (string, error) {
+	inner := shellQuote("echo " + readyMarker + " >&2; " + command)
+	user := shellQuote(config.User)
+
+	switch config.Method {
+	case EscalateSudo:
+		return "sudo -S -p " + shellQuote(promptMarker) + " -u " + user + " -- sh -c " + inner, nil
+	case EscalateSu:
+		return "su " + user + " -c " + inner, nil
+	case EscalateDoas:
+		return "doas -u " + user + " sh -c " + inner, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrSudoUnsupportedMethod, config.Method)
+	}
+}
+
+// escalationWriter filters the stream carrying the escalation prompt until the ready marker appears
+type escalationWriter struct {
+	out          io.Writer
+	readyMarker  string
+	promptMarker string
+	credentials  CredentialProvider
+	stdin        io.WriteCloser
+	onReady      func()
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	ready   bool
+	prompts int
+	failure error
+}
+
+/*
+* Buffers output until escalation completes, answering password prompts and hiding them from the output
+* Inputs: p ([]byte) - data written by the SSH session
+* Outputs: int containing len(p), error if writing to the wrapped writer fails
+ */
+func (ew *escalationWriter) Write(p []byte) (int, error) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+
+	if ew.ready {
+		return ew.forward(p, len(p))
+	}
+
+	ew.buf.Write(p)
+	text := ew.buf.String()
+
+	if i := strings.Index(text, ew.readyMarker); i >= 0 {
+		ew.ready = true
+		rest := text[i+len(ew.readyMarker):]
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, "\r"), "\n")
+		ew.buf.Reset()
+		ew.onReady()
+		return ew.forward([]byte(rest), len(p))
+	}
+
+	if ew.failure == nil {
+		if before, ok := ew.cutPrompt(text); ok {
+			ew.buf.Reset()
+			ew.buf.WriteString(before)
+			ew.answerPrompt(before)
+		}
+	}
+	return len(p), nil
+}
+
+/*
+* Internal helper that writes data to the wrapped writer, discarding it when no writer is set
+* Inputs: data ([]byte) - data to forward, n (int) - byte count reported to the caller
+* Outputs: int containing n, error if the wrapped writer fails
+ */
+func (ew *escalationWriter) forward(data []byte, n int) (int, error) {
+	if ew.out == nil || len(data) == 0 {
+		return n, nil
+	}
+	if _, err := ew.out.Write(data); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+/*
+* Internal helper that detects a password prompt at the end of the buffered output
+* Inputs: text (string) - buffered output
+* Outputs: string containing the output preceding the prompt, bool - true if a prompt was found
+ */
+func (ew *escalationWriter) cutPrompt(text string) (string, bool) {
+	if i := strings.Index(text, ew.promptMarker); i >= 0 {
+		return text[:i], true
+	}
+
+	// su and doas prompts cannot be customised, so look for "...password:" ending the output
+	trimmed := strings.TrimRight(text, " ")
+	lineStart := strings.LastIndexAny(trimmed, "\r\n") + 1
+	line := trimmed[lineStart:]
+	if strings.HasSuffix(line, ":") && strings.Contains(strings.ToLower(line), "password") {
+		return text[:lineStart], true
+	}
+	return "", false
+}
+
+/*
+* Internal helper that sends the password for a prompt, treating a repeated prompt as a rejected password
+* Inputs: before (string) - output printed before the prompt
+* Outputs: none (records failures and closes stdin to abort the escalation tool)
+ */
+func (ew *escalationWriter) answerPrompt(before string) {
+	ew.prompts++
+	switch {
+	case ew.prompts > 1:
+		ew.failure = &SudoError{Err: ErrSudoWrongPassword, Output: strings.TrimSpace(before)}
+	case ew.credentials == nil:
+		ew.failure = &SudoError{Err: ErrSudoPasswordRequired}
+	default:
+		password, err := ew.credentials.Password()
+		if err != nil {
+			ew.failure = fmt.Errorf("privilege escalation failed: credential provider: %w", err)
+		} else if _, err := io.WriteString(ew.stdin, password+"\n"); err != nil {
+			ew.failure = err
+		}
+	}
+
+	if ew.failure != nil {
+		ew.stdin.Close()
+	}
+}
+
+/*
+* Internal helper that converts the session result into the error reported to the caller
+* Inputs: waitErr (error) - result of session.Wait
+* Outputs: error - waitErr once escalation succeeded, otherwise a *SudoError or escalation failure
+ */
+func (ew *escalationWriter) result(waitErr error) error {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+
+	if ew.ready {
+		return waitErr
+	}
+	if ew.failure != nil {
+		return ew.failure
+	}
+
+	message := strings.TrimSpace(ew.buf.String())
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "not in the sudoers"),
+		strings.Contains(lower, "is not allowed to"),
+		strings.Contains(lower, "not permitted"):
+		return &SudoError{Err: ErrSudoNotAllowed, Output: message}
+	case strings.Contains(lower, "incorrect password"),
+		strings.Contains(lower, "sorry, try again"),
+		strings.Contains(lower, "authentication failure"),
+		strings.Contains(lower, "authentication failed"):
+		return &SudoError{Err: ErrSudoWrongPassword, Output: message}
+	case waitErr != nil:
+		return fmt.Errorf("privilege escalation failed: %w: %s", waitErr, message)
+	default:
+		return errors.New("privilege escalation failed: command finished before privileges were granted")
+	}
+}
+
+// lastByteWriter remembers the last byte written through it
+type lastByteWriter struct {
+	w    io.Writer
+	last byte
+}
+
+/*
+* Writes data to the wrapped writer and records its final byte
+* Inputs: p ([]byte) - data to write
+* Outputs: int containing bytes written, error if the wrapped writer fails
+ */
+func (lw *lastByteWriter) Write(p []byte) (int, error) {
+	n, err := lw.w.Write(p)
+	if n > 0 {
+		lw.last = p[n-1]
+	}
+	return n, err
+}
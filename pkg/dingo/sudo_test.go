@@ -0,0 +1,271 @@
+package dingo
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeSudo mimics sudo -S: it prompts on stderr, accepts "secret" and gives up after three attempts
+const fakeSudo = `#!/bin/sh
+prompt="Password:"
+while [ $# -gt 0 ]; do
+	case "$1" in
+		-S) shift ;;
+		-p) prompt="$2"; shift 2 ;;
+		-u) shift 2 ;;
+		--) shift; break ;;
+		*) break ;;
+	esac
+done
+if [ -n "$FAKE_SUDO_DENY" ]; then
+	echo "testuser is not in the sudoers file.  This incident will be reported." >&2
+	exit 1
+fi
+if [ -n "$FAKE_SUDO_NOPASSWD" ]; then
+	exec "$@"
+fi
+tries=0
+while [ $tries -lt 3 ]; do
+	printf '%s' "$prompt" >&2
+	IFS= read -r pw || { echo "sudo: no password was provided" >&2; exit 1; }
+	if [ "$pw" = "secret" ]; then
+		exec "$@"
+	fi
+	echo "Sorry, try again." >&2
+	tries=$((tries+1))
+done
+echo "sudo: 3 incorrect password attempts" >&2
+exit 1
+`
+
+/*
+* Test helper that installs the fake sudo on PATH and connects to an in-process exec server
+* Inputs: t (*testing.T) - test context
+* Outputs: SSHClient connected to the exec server
+ */
+func createSudoTestClient(t *testing.T) SSHClient {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(fakeSudo), 0755); err != nil {
+		t.Fatalf("Failed to write fake sudo: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return newClient(createExecSSHServer(t), nil)
+}
+
+func TestSudo_Command_Success(t *testing.T) {
+	client := createSudoTestClient(t)
+
+	var stdout, stderr bytes.Buffer
+	err := client.Command("echo elevated; echo warning >&2").Sudo(WithSudoPassword("secret")).SetStdio(&stdout, &stderr).Run()
+	if err != nil {
+		t.Fatalf("Sudo command failed: %v", err)
+	}
+
+	if stdout.String() != "elevated\n" {
+		t.Errorf("Unexpected stdout: %q", stdout.String())
+	}
+	if stderr.String() != "warning\n" {
+		t.Errorf("Unexpected stderr: %q", stderr.String())
+	}
+	for _, leaked := range []string{"secret", "DINGO-"} {
+		if strings.Contains(stdout.String()+stderr.String(), leaked) {
+			t.Errorf("Output leaked %q", leaked)
+		}
+	}
+}
+
+func TestSudo_Script_WithStdin(t *testing.T) {
+	client := createSudoTestClient(t)
+
+	output, err := client.Script("echo line1\necho \"$1\"\n", WithArgs("arg")).Sudo(WithSudoPassword("secret")).Output()
+	if err != nil {
+		t.Fatalf("Sudo script failed: %v", err)
+	}
+	if string(output) != "line1\narg\n" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+
+	output, err = client.Script("echo plain\n").Sudo(WithSudoPassword("secret")).Output()
+	if err != nil {
+		t.Fatalf("Sudo script without options failed: %v", err)
+	}
+	if string(output) != "plain\n" {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestSudo_CachedCredentials(t *testing.T) {
+	client := createSudoTestClient(t)
+	t.Setenv("FAKE_SUDO_NOPASSWD", "1")
+
+	// Without a prompt the password must not be sent, or the script would read it as input
+	output, err := client.Script("read line; echo \"got $line\"\n").Sudo(WithSudoPassword("secret")).Output()
+	if err != nil {
+		t.Fatalf("Sudo script failed: %v", err)
+	}
+	if strings.Contains(string(output), "secret") {
+		t.Errorf("Password was sent without a prompt: %q", output)
+	}
+}
+
+func TestSudo_WrongPassword(t *testing.T) {
+	client := createSudoTestClient(t)
+
+	var stderr bytes.Buffer
+	err := client.Command("echo never").Sudo(WithSudoPassword("wrong")).SetStdio(nil, &stderr).Run()
+	if !errors.Is(err, ErrSudoWrongPassword) {
+		t.Fatalf("Expected ErrSudoWrongPassword, got %v", err)
+	}
+
+	var sudoErr *SudoError
+	if !errors.As(err, &sudoErr) || !strings.Contains(sudoErr.Output, "Sorry, try again") {
+		t.Errorf("Expected SudoError with remote message, got %#v", err)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("Escalation output leaked to stderr: %q", stderr.String())
+	}
+}
+
+func TestSudo_NotAllowed(t *testing.T) {
+	client := createSudoTestClient(t)
+	t.Setenv("FAKE_SUDO_DENY", "1")
+
+	_, err := client.Command("id").Sudo(WithSudoPassword("secret")).Output()
+	if !errors.Is(err, ErrSudoNotAllowed) {
+		t.Fatalf("Expected ErrSudoNotAllowed, got %v", err)
+	}
+}
+
+func TestSudo_PasswordRequired(t *testing.T) {
+	client := createSudoTestClient(t)
+
+	_, err := client.Command("id").Sudo().Output()
+	if !errors.Is(err, ErrSudoPasswordRequired) {
+		t.Fatalf("Expected ErrSudoPasswordRequired, got %v", err)
+	}
+}
+
+func TestSudo_CredentialProviderError(t *testing.T) {
+	client := createSudoTestClient(t)
+	providerErr := errors.New("vault unavailable")
+
+	_, err := client.Command("id").Sudo(WithCredentials(PasswordFunc(func() (string, error) {
+		return "", providerErr
+	}))).Output()
+	if !errors.Is(err, providerErr) {
+		t.Fatalf("Expected provider error, got %v", err)
+	}
+}
+
+func TestSudo_CommandExitStatus(t *testing.T) {
+	client := createSudoTestClient(t)
+
+	err := client.Command("exit 4").Sudo(WithSudoPassword("secret")).Run()
+	var sudoErr *SudoError
+	if errors.As(err, &sudoErr) {
+		t.Fatalf("Command failure must not be reported as escalation failure: %v", err)
+	}
+	if err == nil {
+		t.Error("Expected command exit status error")
+	}
+}
+
+func TestEscalationCommand(t *testing.T) {
+	tests := []struct {
+		method   EscalationMethod
+		expected string
+	}{
+		{EscalateSudo, `sudo -S -p 'P:' -u 'root' -- sh -c 'echo R >&2; id'`},
+		{EscalateSu, `su 'root' -c 'echo R >&2; id'`},
+		{EscalateDoas, `doas -u 'root' sh -c 'echo R >&2; id'`},
+	}
+
+	for _, tt := range tests {
+		got, err := escalationCommand(&SudoConfig{Method: tt.method, User: "root"}, "id", "R", "P:")
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", tt.method, err)
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.method, tt.expected, got)
+		}
+	}
+
+	if _, err := escalationCommand(&SudoConfig{Method: "pkexec"}, "id", "R", "P:"); !errors.Is(err, ErrSudoUnsupportedMethod) {
+		t.Errorf("Expected ErrSudoUnsupportedMethod, got %v", err)
+	}
+}
+
+func TestEscalationWriter_TerminalPrompt(t *testing.T) {
+	var out, stdin bytes.Buffer
+	ready := false
+	ew := &escalationWriter{
+		out:          &out,
+		readyMarker:  "READY",
+		promptMarker: "PROMPT:",
+		credentials:  StaticPassword("pw"),
+		stdin:        nopWriteCloser{&stdin},
+		onReady:      func() { ready = true },
+	}
+
+	// su style prompt split across writes
+	ew.Write([]byte("Pass"))
+	ew.Write([]byte("word: "))
+	if stdin.String() != "pw\n" {
+		t.Fatalf("Expected password to be sent, got %q", stdin.String())
+	}
+
+	ew.Write([]byte("\r\nREADY\r\nhello\r\n"))
+	if !ready {
+		t.Error("Expected ready callback")
+	}
+	if out.String() != "hello\r\n" {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+	if err := ew.result(nil); err != nil {
+		t.Errorf("Unexpected result: %v", err)
+	}
+}
+
+func TestEscalationWriter_ClassifiesFailure(t *testing.T) {
+	ew := &escalationWriter{readyMarker: "READY", promptMarker: "PROMPT:", stdin: nopWriteCloser{&bytes.Buffer{}}}
+	ew.Write([]byte("doas: Operation not permitted\n"))
+
+	if err := ew.result(errors.New("exit 1")); !errors.Is(err, ErrSudoNotAllowed) {
+		t.Errorf("Expected ErrSudoNotAllowed, got %v", err)
+	}
+}
+
+func TestNoEchoTerminal(t *testing.T) {
+	tc := &TerminalConfig{Term: "xterm", Width: 100, Height: 30, Modes: ssh.TerminalModes{ssh.ECHO: 1, ssh.ICRNL: 1}}
+
+	config := noEchoTerminal(tc)
+	if config.Modes[ssh.ECHO] != 0 || config.Modes[ssh.ICRNL] != 1 || config.Width != 100 {
+		t.Errorf("Unexpected terminal config: %+v", config)
+	}
+	if tc.Modes[ssh.ECHO] != 1 {
+		t.Error("noEchoTerminal must not modify the original modes")
+	}
+	if noEchoTerminal(nil).Term != DefaultTerminalConfig.Term {
+		t.Error("Expected defaults for nil config")
+	}
+}
+
+func TestStaticPassword(t *testing.T) {
+	var provider CredentialProvider = StaticPassword("pw")
+	if password, err := provider.Password(); err != nil || password != "pw" {
+		t.Errorf("Unexpected password %q, error %v", password, err)
+	}
+}
+
+// nopWriteCloser adds a no-op Close to a writer
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,235 @@
+package dingo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+/*
+* Creates a CommandExecutor for a script rendered from a text/template with shell-quoted variables
+* Inputs: text (string) - template source, vars (map[string]any) - template variables, opts (...ScriptOption) - script options
+* Outputs: CommandExecutor interface for running the rendered script, rendering errors are returned by Run
+ */
+func (c *client) ScriptTemplate(text string, vars map[string]any, opts ...ScriptOption) CommandExecutor {
+	script, err := RenderScriptTemplate(text, vars)
+	return &remoteScript{
+		client:       c.sshClient,
+		scriptType:   RawScript,
+		script:       script,
+		err:          err,
+		scriptConfig: newScriptConfig(opts),
+		forwardAgent: c.agent,
+	}
+}
+
+/*
+* Creates a CommandExecutor for a script template loaded from a filesystem such as an embed.FS
+* Inputs: fsys (fs.FS) - filesystem holding the templates, name (string) - template path within fsys, vars (map[string]any) - template variables, opts (...ScriptOption) - script options
+* Outputs: CommandExecutor interface for running the rendered script, loading and rendering errors are returned by Run
+ */
+func (c *client) ScriptTemplateFS(fsys fs.FS, name string, vars map[string]any, opts ...ScriptOption) CommandExecutor {
+	script, err := RenderScriptTemplateFS(fsys, name, vars)
+	return &remoteScript{
+		client:       c.sshClient,
+		scriptType:   RawScript,
+		script:       script,
+		err:          err,
+		scriptConfig: newScriptConfig(opts),
+		forwardAgent: c.agent,
+	}
+}
+
+/*
+* Renders a script template, substituting {{.Name}} with the shell-quoted value of vars["Name"]
+* Values keep their type inside the template, so {{if .Debug}} and {{range .Hosts}} work, the output of every
+* action is quoted. Use {{raw "Name"}} for the unquoted value, actions ending in raw or quote are printed as they are
+* Inputs: text (string) - template source, vars (map[string]any) - template variables
+* Outputs: string containing the rendered script, error if parsing fails or a variable is missing
+ */
+func RenderScriptTemplate(text string, vars map[string]any) (string, error) {
+	tmpl, err := template.New("script").Funcs(scriptTemplateFuncs(vars)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	return executeScriptTemplate(tmpl, vars)
+}
+
+/*
+* Renders a script template loaded from a filesystem, templates it includes with {{template "lib.tmpl"}} are loaded
+* from the same directory, paths like "lib/common.tmpl" are relative to it. Other files are not read
+* Inputs: fsys (fs.FS) - filesystem holding the templates, name (string) - template path within fsys, vars (map[string]any) - template variables
+* Outputs: string containing the rendered script, error if loading, parsing or rendering fails
+ */
+func RenderScriptTemplateFS(fsys fs.FS, name string, vars map[string]any) (string, error) {
+	tmpl := template.New(path.Base(name)).Funcs(scriptTemplateFuncs(vars)).Option("missingkey=error")
+
+	// Parse the requested template first so it stays the one that is executed
+	tmpl, err := tmpl.ParseFS(fsys, name)
+	if err != nil {
+		return "", err
+	}
+	if err := loadIncludedTemplates(tmpl, fsys, path.Dir(name)); err != nil {
+		return "", err
+	}
+
+	return executeScriptTemplate(tmpl.Lookup(path.Base(name)), vars)
+}
+
+/*
+* Internal helper that loads the templates included by a template set until every include is resolved
+* Names that are neither defined nor a file are left for execution to report
+* Inputs: tmpl (*template.Template) - parsed template set, fsys (fs.FS) - filesystem holding the templates, dir (string) - directory includes are relative to
+* Outputs: error if an included file cannot be read or parsed
+ */
+func loadIncludedTemplates(tmpl *template.Template, fsys fs.FS, dir string) error {
+	tried := make(map[string]bool)
+	for {
+		var pending []string
+		for _, t := range tmpl.Templates() {
+			walkScriptTemplate(t.Tree.Root, func(node parse.Node) {
+				if include, ok := node.(*parse.TemplateNode); ok && !tried[include.Name] && tmpl.Lookup(include.Name) == nil {
+					tried[include.Name] = true
+					pending = append(pending, include.Name)
+				}
+			})
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		for _, include := range pending {
+			data, err := fs.ReadFile(fsys, path.Join(dir, include))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := tmpl.New(include).Parse(string(data)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+/*
+* Internal helper that executes a parsed script template, the output of its actions is shell-quoted
+* Inputs: tmpl (*template.Template) - parsed template, vars (map[string]any) - template variables
+* Outputs: string containing the rendered script, error if execution fails
+ */
+func executeScriptTemplate(tmpl *template.Template, vars map[string]any) (string, error) {
+	for _, t := range tmpl.Templates() {
+		walkScriptTemplate(t.Tree.Root, quoteTemplateAction)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+/*
+* Internal helper that calls visit for a template node and every node nested in its lists and branches
+* Inputs: node (parse.Node) - node to start at, visit (func(parse.Node)) - called for every node
+* Outputs: none
+ */
+func walkScriptTemplate(node parse.Node, visit func(parse.Node)) {
+	if node == nil {
+		return
+	}
+	visit(node)
+
+	var branch *parse.BranchNode
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkScriptTemplate(child, visit)
+		}
+	case *parse.IfNode:
+		branch = &n.BranchNode
+	case *parse.RangeNode:
+		branch = &n.BranchNode
+	case *parse.WithNode:
+		branch = &n.BranchNode
+	}
+	if branch != nil {
+		walkScriptTemplate(branch.List, visit)
+		if branch.ElseList != nil {
+			walkScriptTemplate(branch.ElseList, visit)
+		}
+	}
+}
+
+/*
+* Internal helper that makes an action print its result shell-quoted by appending the quote function to its pipeline
+* Assignments print nothing and actions already ending in quote or raw are left alone
+* Inputs: node (parse.Node) - template node, only actions are changed
+* Outputs: none
+ */
+func quoteTemplateAction(node parse.Node) {
+	action, ok := node.(*parse.ActionNode)
+	if !ok || action.Pipe == nil || len(action.Pipe.Decl) > 0 || len(action.Pipe.Cmds) == 0 {
+		return
+	}
+	last := action.Pipe.Cmds[len(action.Pipe.Cmds)-1]
+	if ident, ok := last.Args[0].(*parse.IdentifierNode); ok && (ident.Ident == "quote" || ident.Ident == "raw") {
+		return
+	}
+	quote := parse.NewIdentifier("quote").SetTree(nil).SetPos(action.Pos)
+	action.Pipe.Cmds = append(action.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: action.Pos, Args: []parse.Node{quote}})
+}
+
+/*
+* Internal helper that builds the function map available inside script templates
+* Inputs: vars (map[string]any) - template variables, used by raw
+* Outputs: template.FuncMap containing the quote and raw functions
+ */
+func scriptTemplateFuncs(vars map[string]any) template.FuncMap {
+	return template.FuncMap{
+		"quote": quoteTemplateValue,
+		"raw": func(name string) (string, error) {
+			value, ok := vars[name]
+			if !ok {
+				return "", fmt.Errorf("template variable %q is not set (available: %s)", name, strings.Join(sortedKeys(vars), ", "))
+			}
+			return fmt.Sprint(value), nil
+		},
+	}
+}
+
+/*
+* Internal helper that shell-quotes a template value, string slices become a list of quoted words
+* Inputs: value (any) - value to quote
+* Outputs: string containing the quoted value
+ */
+func quoteTemplateValue(value any) string {
+	if values, ok := value.([]string); ok {
+		return strings.TrimPrefix(joinCommand("", values), " ")
+	}
+	return shellQuote(fmt.Sprint(value))
+}
+
+/*
+* Internal helper that returns the keys of a variable map in sorted order
+* Inputs: vars (map[string]any) - variable map
+* Outputs: []string containing the sorted keys
+ */
+func sortedKeys(vars map[string]any) []string {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+package dingo
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRenderScriptTemplate_QuotesValues(t *testing.T) {
+	script, err := RenderScriptTemplate("echo {{.Name}} {{.Files}} {{.Count}}", map[string]any{
+		"Name":  "it's $(whoami)",
+		"Files": []string{"a b", "c"},
+		"Count": 3,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `echo 'it'\''s $(whoami)' 'a b' 'c' '3'`
+	if script != expected {
+		t.Errorf("Expected %q, got %q", expected, script)
+	}
+}
+
+func TestRenderScriptTemplate_RawAndQuote(t *testing.T) {
+	script, err := RenderScriptTemplate(`PORT={{raw "Port"}}; echo {{quote (printf "%s:%s" (raw "Host") (raw "Port"))}}`, map[string]any{
+		"Host": "example.com",
+		"Port": 8080,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "PORT=8080; echo 'example.com:8080'"
+	if script != expected {
+		t.Errorf("Expected %q, got %q", expected, script)
+	}
+}
+
+func TestRenderScriptTemplate_TypedValues(t *testing.T) {
+	text := `{{if .Debug}}set -x; {{end}}{{range .Hosts}}ping {{.}}; {{end}}{{$user := .User}}echo {{$user}}`
+	vars := map[string]any{
+		"Debug": false,
+		"Hosts": []string{"web 1", "db"},
+		"User":  "o'neil",
+	}
+
+	script, err := RenderScriptTemplate(text, vars)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `ping 'web 1'; ping 'db'; echo 'o'\''neil'`
+	if script != expected {
+		t.Errorf("Expected %q, got %q", expected, script)
+	}
+
+	vars["Debug"] = true
+	if script, _ := RenderScriptTemplate(text, vars); !strings.HasPrefix(script, "set -x; ") {
+		t.Errorf("Expected the debug branch, got %q", script)
+	}
+}
+
+func TestRenderScriptTemplate_MissingVariable(t *testing.T) {
+	if _, err := RenderScriptTemplate("echo {{.Missing}}", map[string]any{}); err == nil {
+		t.Error("Expected error for missing variable")
+	}
+
+	_, err := RenderScriptTemplate(`echo {{raw "Missing"}}`, map[string]any{"Other": 1})
+	if err == nil || !strings.Contains(err.Error(), "Missing") {
+		t.Errorf("Expected error naming the missing variable, got %v", err)
+	}
+}
+
+func TestRenderScriptTemplate_ParseError(t *testing.T) {
+	if _, err := RenderScriptTemplate("echo {{.Name", nil); err == nil {
+		t.Error("Expected parse error")
+	}
+}
+
+func TestRenderScriptTemplateFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"scripts/deploy.sh.tmpl": {Data: []byte(`{{template "lib.tmpl" .}}deploy {{.Env}}`)},
+		"scripts/lib.tmpl":       {Data: []byte(`log() { echo "$@"; }` + "\n")},
+	}
+
+	script, err := RenderScriptTemplateFS(fsys, "scripts/deploy.sh.tmpl", map[string]any{"Env": "prod"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "log() { echo \"$@\"; }\ndeploy 'prod'"
+	if script != expected {
+		t.Errorf("Expected %q, got %q", expected, script)
+	}
+}
+
+func TestRenderScriptTemplateFS_OnlyIncludedTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"scripts/deploy.sh.tmpl":  {Data: []byte(`{{template "lib/common.tmpl" .}}deploy {{.Env}}`)},
+		"scripts/lib/common.tmpl": {Data: []byte(`{{template "log.tmpl"}}`)},
+		"scripts/log.tmpl":        {Data: []byte("log() { :; }\n")},
+		"scripts/broken.sh.tmpl":  {Data: []byte(`{{if}}`)},
+		"scripts/unrelated.tmpl":  {Data: []byte(`{{.Missing`)},
+	}
+
+	script, err := RenderScriptTemplateFS(fsys, "scripts/deploy.sh.tmpl", map[string]any{"Env": "prod"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := "log() { :; }\ndeploy 'prod'"; script != expected {
+		t.Errorf("Expected %q, got %q", expected, script)
+	}
+
+	fsys["scripts/missing.sh.tmpl"] = &fstest.MapFile{Data: []byte(`{{template "nowhere.tmpl"}}`)}
+	if _, err := RenderScriptTemplateFS(fsys, "scripts/missing.sh.tmpl", nil); err == nil {
+		t.Error("Expected error for a missing include")
+	}
+}
+
+func TestRenderScriptTemplateFS_NotFound(t *testing.T) {
+	if _, err := RenderScriptTemplateFS(fstest.MapFS{}, "missing.tmpl", nil); err == nil {
+		t.Error("Expected error for missing template")
+	}
+}
+
+func TestClient_ScriptTemplate_RenderError(t *testing.T) {
+	client := newClient(nil, nil)
+
+	cmd := client.ScriptTemplate("echo {{.Missing}}", nil)
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected rendering error from Run")
+	}
+
+	cmd = client.ScriptTemplateFS(fstest.MapFS{}, "missing.tmpl", nil)
+	if _, err := cmd.Output(); err == nil {
+		t.Error("Expected loading error from Output")
+	}
+}
+
+func TestClient_ScriptTemplate_Run(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	output, err := client.ScriptTemplate(`echo {{.Greeting}} "$1"`, map[string]any{"Greeting": "hello; exit 1"}, WithArgs("world")).Output()
+	if err != nil {
+		t.Fatalf("ScriptTemplate failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "hello; exit 1 world" {
+		t.Errorf("Unexpected output: %q", got)
+	}
+}
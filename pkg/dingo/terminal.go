@@ -0,0 +1,59 @@
+package dingo
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+/*
+* Detects the size and type of the local terminal attached to stdin
+* Inputs: none (reads os.Stdin and the TERM environment variable)
+* Outputs: *TerminalConfig matching the local terminal, error if stdin is not a terminal
+ */
+func LocalTerminalConfig() (*TerminalConfig, error) {
+	width, height, err := term.GetSize(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, err
+	}
+
+	termType := os.Getenv("TERM")
+	if termType == "" {
+		termType = DefaultTerminalConfig.Term
+	}
+
+	return &TerminalConfig{
+		Term:   termType,
+		Width:  width,
+		Height: height,
+		Modes:  DefaultTerminalConfig.Modes,
+	}, nil
+}
+
+/*
+* Runs an interactive shell attached to the local terminal: stdin is switched into raw mode for the duration
+* of the session and restored afterwards, and local window size changes are sent to the remote PTY
+* Falls back to a plain Start when stdin is not a terminal
+* Inputs: shell (Shell) - interactive shell to run, command (string) - program to run instead of the login shell, "" for the shell
+* Outputs: error if the terminal cannot be prepared or the shell fails, nil on success
+ */
+func RunInLocalTerminal(shell Shell, command string) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return shell.Start(command)
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+
+	done := make(chan struct{})
+	defer close(done)
+	go watchTerminalSize(fd, done, func(width, height int) {
+		shell.Resize(width, height)
+	})
+
+	return shell.Start(command)
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package dingo
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+/*
+* Internal helper that reports the terminal size whenever SIGWINCH is received
+* Inputs: fd (int) - terminal file descriptor, done (<-chan struct{}) - closed to stop watching, onResize (func(width, height int)) - callback for new sizes
+* Outputs: none (returns when done is closed)
+ */
+func watchTerminalSize(fd int, done <-chan struct{}, onResize func(width, height int)) {
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-winch:
+			if width, height, err := term.GetSize(fd); err == nil {
+				onResize(width, height)
+			}
+		}
+	}
+}
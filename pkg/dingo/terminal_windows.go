@@ -0,0 +1,34 @@
+//go:build windows
+
+package dingo
+
+import (
+	"time"
+
+	"golang.org/x/term"
+)
+
+/*
+* Internal helper that polls the console size and reports changes, Windows has no SIGWINCH
+* Inputs: fd (int) - console handle, done (<-chan struct{}) - closed to stop watching, onResize (func(width, height int)) - callback for new sizes
+* Outputs: none (returns when done is closed)
+ */
+func watchTerminalSize(fd int, done <-chan struct{}, onResize func(width, height int)) {
+	lastWidth, lastHeight, _ := term.GetSize(fd)
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			width, height, err := term.GetSize(fd)
+			if err == nil && (width != lastWidth || height != lastHeight) {
+				lastWidth, lastHeight = width, height
+				onResize(width, height)
+			}
+		}
+	}
+}
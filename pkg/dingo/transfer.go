@@ -0,0 +1,1166 @@
+package dingo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrSymlinkLoop is returned when following symbolic links leads back into a directory that is being transferred
+var ErrSymlinkLoop = errors.New("symbolic link loop")
+
+// transferBufferSize is the size of the reads and writes of a file copy, large enough for the sftp package to pipeline them
+const transferBufferSize = 1 << 20
+
+// transferReader is a file opened for reading by a transfer
+type transferReader interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+}
+
+// transferWriter is a file opened for writing by a transfer
+type transferWriter interface {
+	io.WriteCloser
+	io.WriterAt
+	Sync() error
+}
+
+// transferFS is one side of a directory transfer, implemented for the local filesystem and for SFTP
+type transferFS interface {
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+	ReadLink(name string) (string, error)
+	RealPath(name string) (string, error)
+	Open(name string) (transferReader, error)
+	Create(name string, perm os.FileMode) (transferWriter, error)
+	OpenAt(name string, offset int64) (transferWriter, error)
+	Truncate(name string, size int64) error
+	MkdirAll(name string) error
+	Symlink(target, name string) error
+	Remove(name string) error
+	Rename(oldname, newname string) error
+	Sync(f transferWriter) error
+	Chmod(name string, mode os.FileMode) error
+	Chown(name string, uid, gid int) error
+	Chtimes(name string, atime, mtime time.Time) error
+	Attributes(name string, info os.FileInfo) (fileAttributes, error)
+	Checksum(name string, algo ChecksumAlgorithm) (string, error)
+	ChecksumPrefix(name string, n int64, algo ChecksumAlgorithm) (string, error)
+	Join(elem ...string) string
+}
+
+// fileAttributes is the metadata a transfer preserves on request, in addition to the mode and modification time
+type fileAttributes struct {
+	atime time.Time
+	uid   int // -1 when the platform has no owner ids
+	gid   int
+}
+
+// localTransferFS implements transferFS on the local filesystem
+type localTransferFS struct{}
+
+// sftpTransferFS implements transferFS on the remote host
+type sftpTransferFS struct {
+	client *sftp.Client
+	ssh    *ssh.Client // Runs the remote checksum commands, nil to always stream files back for hashing
+}
+
+/*
+* Returns information about a local path, following symbolic links
+* Inputs: name (string) - path
+* Outputs: os.FileInfo, error if the path cannot be examined
+ */
+func (localTransferFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+/*
+* Lists a local directory without following symbolic links
+* Inputs: name (string) - directory path
+* Outputs: []os.FileInfo for the entries, error if the directory cannot be read
+ */
+func (localTransferFS) ReadDir(name string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+/*
+* Returns the target of a local symbolic link
+* Inputs: name (string) - link path
+* Outputs: string containing the target, error if the link cannot be read
+ */
+func (localTransferFS) ReadLink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+/*
+* Returns the absolute local path with symbolic links resolved
+* Inputs: name (string) - path
+* Outputs: string containing the resolved path, error if it cannot be resolved
+ */
+func (localTransferFS) RealPath(name string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(resolved)
+}
+
+/*
+* Opens a local file for reading
+* Inputs: name (string) - file path
+* Outputs: transferReader for the contents, error if the file cannot be opened
+ */
+func (localTransferFS) Open(name string) (transferReader, error) {
+	return os.Open(name)
+}
+
+/*
+* Creates or truncates a local file for writing
+* Inputs: name (string) - file path, perm (os.FileMode) - permissions of a new file
+* Outputs: transferWriter for the contents, error if the file cannot be created
+ */
+func (localTransferFS) Create(name string, perm os.FileMode) (transferWriter, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+}
+
+/*
+* Opens an existing local file for writing at an offset, keeping the data before it
+* Inputs: name (string) - file path, offset (int64) - position to continue writing at
+* Outputs: transferWriter positioned at the offset, error if the file cannot be opened
+ */
+func (localTransferFS) OpenAt(name string, offset int64) (transferWriter, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+/*
+* Creates a local directory and its missing parents
+* Inputs: name (string) - directory path
+* Outputs: error if the directory cannot be created
+ */
+func (localTransferFS) MkdirAll(name string) error {
+	return os.MkdirAll(name, 0755)
+}
+
+/*
+* Creates a local symbolic link
+* Inputs: target (string) - link target, name (string) - link path
+* Outputs: error if the link cannot be created
+ */
+func (localTransferFS) Symlink(target, name string) error {
+	return os.Symlink(target, name)
+}
+
+/*
+* Removes a local file, link or empty directory
+* Inputs: name (string) - path
+* Outputs: error if the path cannot be removed
+ */
+func (localTransferFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+/*
+* Renames a local file, replacing an existing file at the new name
+* Inputs: oldname (string) - current path, newname (string) - new path
+* Outputs: error if the file cannot be renamed
+ */
+func (localTransferFS) Rename(oldname, newname string) error {
+	return os.Rename(oldname, newname)
+}
+
+/*
+* Flushes a local file to stable storage
+* Inputs: f (transferWriter) - file opened by Create or OpenAt
+* Outputs: error if the file cannot be synced
+ */
+func (localTransferFS) Sync(f transferWriter) error {
+	return f.Sync()
+}
+
+/*
+* Cuts a local file to a size
+* Inputs: name (string) - file path, size (int64) - new size
+* Outputs: error if the file cannot be truncated
+ */
+func (localTransferFS) Truncate(name string, size int64) error {
+	return os.Truncate(name, size)
+}
+
+/*
+* Changes the permissions of a local path
+* Inputs: name (string) - path, mode (os.FileMode) - new permissions
+* Outputs: error if the permissions cannot be changed
+ */
+func (localTransferFS) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+/*
+* Changes the owner of a local path
+* Inputs: name (string) - path, uid (int) - user id, gid (int) - group id
+* Outputs: error if the owner cannot be changed
+ */
+func (localTransferFS) Chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
+
+/*
+* Reads the access time and owner of a local file
+* Inputs: name (string) - path, info (os.FileInfo) - information from Stat
+* Outputs: fileAttributes of the file, error if the file cannot be examined
+ */
+func (localTransferFS) Attributes(name string, info os.FileInfo) (fileAttributes, error) {
+	return localFileAttributes(name, info)
+}
+
+/*
+* Changes the access and modification times of a local path
+* Inputs: name (string) - path, atime (time.Time) - access time, mtime (time.Time) - modification time
+* Outputs: error if the times cannot be changed
+ */
+func (localTransferFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+/*
+* Joins local path elements
+* Inputs: elem (...string) - path elements
+* Outputs: string containing the joined path
+ */
+func (localTransferFS) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+/*
+* Returns information about a remote path, following symbolic links
+* Inputs: name (string) - path
+* Outputs: os.FileInfo, error if the path cannot be examined
+ */
+func (s sftpTransferFS) Stat(name string) (os.FileInfo, error) {
+	return s.client.Stat(name)
+}
+
+/*
+* Lists a remote directory without following symbolic links
+* Inputs: name (string) - directory path
+* Outputs: []os.FileInfo for the entries, error if the directory cannot be read
+ */
+func (s sftpTransferFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return s.client.ReadDir(name)
+}
+
+/*
+* Returns the target of a remote symbolic link
+* Inputs: name (string) - link path
+* Outputs: string containing the target, error if the link cannot be read
+ */
+func (s sftpTransferFS) ReadLink(name string) (string, error) {
+	return s.client.ReadLink(name)
+}
+
+/*
+* Returns the absolute remote path with symbolic links resolved
+* Inputs: name (string) - path
+* Outputs: string containing the resolved path, error if it cannot be resolved
+ */
+func (s sftpTransferFS) RealPath(name string) (string, error) {
+	return s.client.RealPath(name)
+}
+
+/*
+* Opens a remote file for reading
+* Inputs: name (string) - file path
+* Outputs: transferReader for the contents, error if the file cannot be opened
+ */
+func (s sftpTransferFS) Open(name string) (transferReader, error) {
+	return s.client.Open(name)
+}
+
+/*
+* Creates or truncates a remote file for writing
+* Inputs: name (string) - file path, perm (os.FileMode) - permissions of the file
+* Outputs: transferWriter for the contents, error if the file cannot be created
+ */
+func (s sftpTransferFS) Create(name string, perm os.FileMode) (transferWriter, error) {
+	return createRemoteFile(s.client, name, perm)
+}
+
+/*
+* Opens an existing remote file for writing at an offset, keeping the data before it
+* Inputs: name (string) - file path, offset (int64) - position to continue writing at
+* Outputs: transferWriter positioned at the offset, error if the file cannot be opened
+ */
+func (s sftpTransferFS) OpenAt(name string, offset int64) (transferWriter, error) {
+	f, err := s.client.OpenFile(name, os.O_WRONLY)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+/*
+* Creates a remote directory and its missing parents
+* Inputs: name (string) - directory path
+* Outputs: error if the directory cannot be created
+ */
+func (s sftpTransferFS) MkdirAll(name string) error {
+	return s.client.MkdirAll(name)
+}
+
+/*
+* Creates a remote symbolic link
+* Inputs: target (string) - link target, name (string) - link path
+* Outputs: error if the link cannot be created
+ */
+func (s sftpTransferFS) Symlink(target, name string) error {
+	return s.client.Symlink(target, name)
+}
+
+/*
+* Removes a remote file, link or empty directory
+* Inputs: name (string) - path
+* Outputs: error if the path cannot be removed
+ */
+func (s sftpTransferFS) Remove(name string) error {
+	return s.client.Remove(name)
+}
+
+/*
+* Renames a remote file, replacing an existing file at the new name when the server supports posix-rename@openssh.com
+* Without the extension the plain SFTP rename is used, which most servers refuse when the new name exists
+* Inputs: oldname (string) - current path, newname (string) - new path
+* Outputs: error if the file cannot be renamed
+ */
+func (s sftpTransferFS) Rename(oldname, newname string) error {
+	if _, ok := s.client.HasExtension("posix-rename@openssh.com"); ok {
+		return s.client.PosixRename(oldname, newname)
+	}
+	return s.client.Rename(oldname, newname)
+}
+
+/*
+* Flushes a remote file to stable storage when the server supports fsync@openssh.com, otherwise does nothing
+* Inputs: f (transferWriter) - file opened by Create or OpenAt
+* Outputs: error if the server fails to sync the file
+ */
+func (s sftpTransferFS) Sync(f transferWriter) error {
+	if _, ok := s.client.HasExtension("fsync@openssh.com"); !ok {
+		return nil
+	}
+	return f.Sync()
+}
+
+/*
+* Cuts a remote file to a size
+* Inputs: name (string) - file path, size (int64) - new size
+* Outputs: error if the file cannot be truncated
+ */
+func (s sftpTransferFS) Truncate(name string, size int64) error {
+	return s.client.Truncate(name, size)
+}
+
+/*
+* Changes the permissions of a remote path
+* Inputs: name (string) - path, mode (os.FileMode) - new permissions
+* Outputs: error if the permissions cannot be changed
+ */
+func (s sftpTransferFS) Chmod(name string, mode os.FileMode) error {
+	return s.client.Chmod(name, mode)
+}
+
+/*
+* Changes the owner of a remote path
+* Inputs: name (string) - path, uid (int) - user id, gid (int) - group id
+* Outputs: error if the owner cannot be changed
+ */
+func (s sftpTransferFS) Chown(name string, uid, gid int) error {
+	return s.client.Chown(name, uid, gid)
+}
+
+/*
+* Reads the access time and owner of a remote file from the attributes returned by Stat
+* Inputs: name (string) - path, unused, info (os.FileInfo) - information from Stat
+* Outputs: fileAttributes of the file, the modification time and -1 ids when the server sent no attributes, error never
+ */
+func (s sftpTransferFS) Attributes(name string, info os.FileInfo) (fileAttributes, error) {
+	stat, ok := info.Sys().(*sftp.FileStat)
+	if !ok {
+		return fileAttributes{atime: info.ModTime(), uid: -1, gid: -1}, nil
+	}
+	return fileAttributes{atime: stat.AccessTime(), uid: int(stat.UID), gid: int(stat.GID)}, nil
+}
+
+/*
+* Changes the access and modification times of a remote path
+* Inputs: name (string) - path, atime (time.Time) - access time, mtime (time.Time) - modification time
+* Outputs: error if the times cannot be changed
+ */
+func (s sftpTransferFS) Chtimes(name string, atime, mtime time.Time) error {
+	return s.client.Chtimes(name, atime, mtime)
+}
+
+/*
+* Joins remote path elements
+* Inputs: elem (...string) - path elements
+* Outputs: string containing the joined path
+ */
+func (s sftpTransferFS) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// transferStepKind identifies the operation of a transfer step
+type transferStepKind int
+
+const (
+	stepMkdir   transferStepKind = iota // Create a directory
+	stepFile                            // Copy a regular file
+	stepSymlink                         // Recreate a symbolic link
+	stepDirDone                         // Apply a directory's metadata once its entries are written
+)
+
+// transferStep is a single operation of a planned transfer
+type transferStep struct {
+	kind  transferStepKind
+	src   string
+	dst   string
+	info  os.FileInfo     // Source information, for stepSymlink the link itself
+	attrs *fileAttributes // Source access time and owner of files and directories, read while planning when preserving
+	link  string          // Target of a preserved symbolic link
+}
+
+// treeTransfer copies files and directory trees from one transferFS to another
+// The source is walked first so the total size is known before any data is copied
+type treeTransfer struct {
+	src      transferFS
+	dst      transferFS
+	config   *TransferConfig
+	sftp     *SftpConfig // Parallelism of the copies
+	metadata bool        // Apply the source modes and modification times to the destination
+	steps    []transferStep
+	walking  map[string]bool // Resolved paths of the directories being planned, to detect symlink loops
+}
+
+/*
+* Internal helper that returns the remote side of a transfer
+* Inputs: none
+* Outputs: sftpTransferFS using the FileSystem's SFTP session and SSH connection
+ */
+func (rfs *remoteFileSystem) transferFS() sftpTransferFS {
+	return sftpTransferFS{client: rfs.sftp, ssh: rfs.client}
+}
+
+/*
+* Uploads a local directory tree to the remote host, the contents of localDir end up in remoteDir
+* Modes and modification times are preserved, devices, sockets and named pipes are not transferred
+* Inputs: localDir (string) - local directory to upload, remoteDir (string) - remote directory, created if missing, opts (...TransferOption) - symlink policy and include/exclude filters
+* Outputs: error if a file cannot be transferred or SFTP error exists, nil on success
+ */
+func (rfs *remoteFileSystem) UploadDir(localDir, remoteDir string, opts ...TransferOption) error {
+	if rfs.err != nil {
+		return rfs.err
+	}
+	return newTreeTransfer(localTransferFS{}, rfs.transferFS(), rfs.config, opts).run(localDir, remoteDir)
+}
+
+/*
+* Downloads a remote directory tree, the contents of remoteDir end up in localDir
+* Modes and modification times are preserved, devices, sockets and named pipes are not transferred
+* Inputs: remoteDir (string) - remote directory to download, localDir (string) - local directory, created if missing, opts (...TransferOption) - symlink policy and include/exclude filters
+* Outputs: error if a file cannot be transferred or SFTP error exists, nil on success
+ */
+func (rfs *remoteFileSystem) DownloadDir(remoteDir, localDir string, opts ...TransferOption) error {
+	if rfs.err != nil {
+		return rfs.err
+	}
+	return newTreeTransfer(rfs.transferFS(), localTransferFS{}, rfs.config, opts).run(remoteDir, localDir)
+}
+
+/*
+* Internal helper that builds a transfer configuration from the defaults and the given options
+* Inputs: opts ([]TransferOption) - transfer options to apply
+* Outputs: *TransferConfig containing the resulting configuration
+ */
+func newTransferConfig(opts []TransferOption) *TransferConfig {
+	config := *DefaultTransferConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return &config
+}
+
+/*
+* Internal helper that prepares a transfer
+* Inputs: src (transferFS) - side to copy from, dst (transferFS) - side to copy to, sftpConfig (*SftpConfig) - configuration of the FileSystem, opts ([]TransferOption) - transfer options
+* Outputs: *treeTransfer ready to run
+ */
+func newTreeTransfer(src, dst transferFS, sftpConfig *SftpConfig, opts []TransferOption) *treeTransfer {
+	return &treeTransfer{
+		src:     src,
+		dst:     dst,
+		config:  newTransferConfig(opts),
+		sftp:    sftpConfig,
+		walking: make(map[string]bool),
+	}
+}
+
+/*
+* Internal helper that copies a single file, new files get the source permissions
+* The modification time and the rest of the metadata are only applied when preserving
+* Inputs: src (string) - source file, dst (string) - destination file
+* Outputs: error if the file cannot be copied
+ */
+func (t *treeTransfer) runFile(src, dst string) error {
+	info, err := t.src.Stat(src)
+	if err != nil {
+		return err
+	}
+	t.metadata = t.config.Preserve || t.config.PreserveOwner
+	attrs, err := t.sourceAttributes(src, info)
+	if err != nil {
+		return err
+	}
+	t.steps = append(t.steps, transferStep{kind: stepFile, src: src, dst: dst, info: info, attrs: attrs})
+	return t.execute()
+}
+
+/*
+* Internal helper that validates the configuration and copies the source directory into the destination directory
+* Inputs: srcDir (string) - source directory, dstDir (string) - destination directory
+* Outputs: error if the configuration is invalid or a file cannot be transferred
+ */
+func (t *treeTransfer) run(srcDir, dstDir string) error {
+	switch t.config.Symlinks {
+	case SymlinkFollow, SymlinkPreserve, SymlinkSkip:
+	default:
+		return fmt.Errorf("unknown symlink policy %q", t.config.Symlinks)
+	}
+	for _, patterns := range [][]string{t.config.Include, t.config.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+
+	info, err := t.src.Stat(srcDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", srcDir)
+	}
+	t.metadata = true
+	if err := t.planDir(srcDir, dstDir, "", info); err != nil {
+		return err
+	}
+	return t.execute()
+}
+
+/*
+* Internal helper that plans a directory and its entries, the directory's metadata is applied after its entries are written
+* Inputs: src (string) - source directory, dst (string) - destination directory, rel (string) - slash-separated path relative to the transfer root, info (os.FileInfo) - source directory information
+* Outputs: error if the directory cannot be read or a symlink loop is found
+ */
+func (t *treeTransfer) planDir(src, dst, rel string, info os.FileInfo) error {
+	if t.config.Symlinks == SymlinkFollow {
+		resolved, err := t.src.RealPath(src)
+		if err != nil {
+			return err
+		}
+		if t.walking[resolved] {
+			return fmt.Errorf("%w: %s", ErrSymlinkLoop, src)
+		}
+		t.walking[resolved] = true
+		defer delete(t.walking, resolved)
+	}
+
+	// Reading the directory changes its access time
+	attrs, err := t.sourceAttributes(src, info)
+	if err != nil {
+		return err
+	}
+	t.steps = append(t.steps, transferStep{kind: stepMkdir, src: src, dst: dst, info: info})
+	entries, err := t.src.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if err := t.planEntry(t.src.Join(src, name), t.dst.Join(dst, name), path.Join(rel, name), entry); err != nil {
+			return err
+		}
+	}
+	t.steps = append(t.steps, transferStep{kind: stepDirDone, src: src, dst: dst, info: info, attrs: attrs})
+	return nil
+}
+
+/*
+* Internal helper that plans a single directory entry according to its type, the symlink policy and the filters
+* Devices, sockets and named pipes are not transferred
+* Inputs: src (string) - source path, dst (string) - destination path, rel (string) - slash-separated path relative to the transfer root, info (os.FileInfo) - source information, not following links
+* Outputs: error if a link cannot be read or followed
+ */
+func (t *treeTransfer) planEntry(src, dst, rel string, info os.FileInfo) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch t.config.Symlinks {
+		case SymlinkSkip:
+			return nil
+		case SymlinkPreserve:
+			if !t.included(rel) {
+				return nil
+			}
+			target, err := t.src.ReadLink(src)
+			if err != nil {
+				return err
+			}
+			t.steps = append(t.steps, transferStep{kind: stepSymlink, src: src, dst: dst, info: info, link: target})
+			return nil
+		}
+
+		var err error
+		if info, err = t.src.Stat(src); err != nil {
+			return fmt.Errorf("failed to follow symlink %s: %w", src, err)
+		}
+	}
+
+	switch {
+	case info.IsDir():
+		if t.excluded(rel) {
+			return nil
+		}
+		return t.planDir(src, dst, rel, info)
+	case info.Mode().IsRegular():
+		if t.included(rel) {
+			attrs, err := t.sourceAttributes(src, info)
+			if err != nil {
+				return err
+			}
+			t.steps = append(t.steps, transferStep{kind: stepFile, src: src, dst: dst, info: info, attrs: attrs})
+		}
+	}
+	return nil
+}
+
+/*
+* Internal helper that executes the planned steps while reporting progress
+* Directories and links are created first, then the files are copied by the configured number of workers
+* and finally the directories get their metadata, deepest first
+* Inputs: none
+* Outputs: error from the first step that fails, or an unknown checksum algorithm when verifying
+ */
+func (t *treeTransfer) execute() error {
+	if t.config.Verify {
+		if _, err := newChecksumHash(t.config.Checksum); err != nil {
+			return err
+		}
+	}
+
+	var files []transferStep
+	var size int64
+	for _, step := range t.steps {
+		if step.kind == stepFile {
+			files = append(files, step)
+			size += step.info.Size()
+		}
+	}
+	tracker := newTransferTracker(t.config, len(files), size)
+
+	for _, step := range t.steps {
+		if step.kind == stepMkdir || step.kind == stepSymlink {
+			if err := t.executeStep(step, tracker); err != nil {
+				return err
+			}
+		}
+	}
+	if err := t.copyFiles(files, tracker); err != nil {
+		return err
+	}
+	for _, step := range t.steps {
+		if step.kind == stepDirDone {
+			if err := t.executeStep(step, tracker); err != nil {
+				return err
+			}
+		}
+	}
+	tracker.finish()
+	return nil
+}
+
+/*
+* Internal helper that copies files with a pool of workers, no new files are started after a failure
+* Inputs: files ([]transferStep) - file steps, tracker (*transferTracker) - progress of the transfer
+* Outputs: error from the first file that fails
+ */
+func (t *treeTransfer) copyFiles(files []transferStep, tracker *transferTracker) error {
+	workers := min(max(t.sftp.Workers, 1), len(files))
+	queue := make(chan transferStep)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for step := range queue {
+				if err := t.executeStep(step, tracker); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+dispatch:
+	for _, step := range files {
+		select {
+		case queue <- step:
+		case err = <-errs:
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if err == nil && len(errs) > 0 {
+		err = <-errs
+	}
+	return err
+}
+
+/*
+* Internal helper that executes a single planned step
+* Inputs: step (transferStep) - step to execute, tracker (*transferTracker) - progress of the transfer
+* Outputs: error if the step fails
+ */
+func (t *treeTransfer) executeStep(step transferStep, tracker *transferTracker) error {
+	switch step.kind {
+	case stepMkdir:
+		if err := t.dst.MkdirAll(step.dst); err != nil {
+			return fmt.Errorf("failed to create %s: %w", step.dst, err)
+		}
+	case stepSymlink:
+		t.dst.Remove(step.dst) // Replace what a previous transfer left, a missing entry is fine
+		if err := t.dst.Symlink(step.link, step.dst); err != nil {
+			return fmt.Errorf("failed to create symlink %s: %w", step.dst, err)
+		}
+	case stepDirDone:
+		return t.applyMetadata(step)
+	case stepFile:
+		if err := t.copyFile(step, tracker); err != nil {
+			return err
+		}
+		if t.metadata {
+			return t.applyMetadata(step)
+		}
+	}
+	return nil
+}
+
+/*
+* Internal helper that copies the contents of a regular file, continuing an interrupted copy when resuming
+* Resumed copies write a partial file next to the destination, which replaces it once complete. If a copy fails the
+* file written is cut to the data known to be written, so a resumed transfer can continue from there
+* Atomic copies write to a temporary file next to the destination instead, which replaces it once complete or is removed on failure
+* With Verify the copy is checked against the source before a partial or temporary file replaces the destination
+* Inputs: step (transferStep) - file step, tracker (*transferTracker) - progress of the transfer
+* Outputs: error if the file cannot be copied
+ */
+func (t *treeTransfer) copyFile(step transferStep, tracker *transferTracker) error {
+	size := step.info.Size()
+	target, offset := step.dst, int64(0)
+	switch {
+	case t.config.Resume:
+		var err error
+		if target, offset, err = t.resumeTarget(step); err != nil {
+			return err
+		}
+	case t.config.Atomic:
+		target = atomicTempName(step.dst)
+	}
+	tracker.startFile(step.src)
+	tracker.skip(offset)
+	if offset == size && offset > 0 {
+		tracker.endFile()
+		return t.finishFile(step, target)
+	}
+
+	in, err := t.src.Open(step.src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var out transferWriter
+	if offset > 0 {
+		out, err = t.dst.OpenAt(target, offset)
+	} else {
+		out, err = t.dst.Create(target, step.info.Mode().Perm())
+	}
+	if err != nil {
+		return err
+	}
+
+	var written int64
+	if t.config.Resume {
+		// A dropped connection must leave a partial file without gaps, its size is where the next run continues
+		written, err = copyRange(in, orderedWriter{out, t.packetSize()}, offset, size-offset, tracker)
+	} else if chunk := t.sftp.ChunkSize; t.sftp.ParallelChunks > 1 && chunk > 0 && size-offset >= 2*chunk {
+		written, err = t.copyChunks(in, out, offset, size, tracker)
+	} else {
+		written, err = copyRange(in, out, offset, size-offset, tracker)
+	}
+	if err == nil && t.config.Atomic {
+		err = t.dst.Sync(out)
+	}
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if t.config.Atomic {
+			t.dst.Remove(target)
+		} else {
+			t.dst.Truncate(target, offset+written)
+		}
+		return fmt.Errorf("failed to copy %s: %w", step.src, err)
+	}
+	tracker.endFile()
+	return t.finishFile(step, target)
+}
+
+/*
+* Internal helper that verifies a copied file and moves a partial or temporary file over the destination
+* Inputs: step (transferStep) - file step, target (string) - file holding the copy
+* Outputs: error if verification fails or the file cannot be renamed
+ */
+func (t *treeTransfer) finishFile(step transferStep, target string) error {
+	if t.config.Verify {
+		if err := t.verifyFile(step, target); err != nil {
+			if target != step.dst {
+				t.dst.Remove(target) // Keep the previous destination rather than a copy that does not match
+			}
+			return err
+		}
+	}
+	if target != step.dst {
+		if err := t.dst.Rename(target, step.dst); err != nil {
+			if t.config.Atomic {
+				t.dst.Remove(target)
+			}
+			return fmt.Errorf("failed to copy %s: %w", step.src, err)
+		}
+	}
+	return nil
+}
+
+// orderedWriter splits writes into single packets that are acknowledged one after the other
+// The SFTP session sends larger writes as concurrent packets, which a dropped connection can leave with gaps
+type orderedWriter struct {
+	transferWriter
+	packet int
+}
+
+/*
+* Writes data at an offset one packet at a time, front to back
+* Inputs: p ([]byte) - data to write, off (int64) - offset of the data
+* Outputs: int containing the bytes written before the first error, error if a write fails
+ */
+func (w orderedWriter) WriteAt(p []byte, off int64) (int, error) {
+	var done int
+	for done < len(p) {
+		n, err := w.transferWriter.WriteAt(p[done:min(len(p), done+w.packet)], off+int64(done))
+		done += n
+		if err != nil {
+			return done, err
+		}
+	}
+	return done, nil
+}
+
+/*
+* Internal helper that returns the largest write sent as a single SFTP packet
+* Inputs: none
+* Outputs: int containing the configured packet size, 32768 when unset
+ */
+func (t *treeTransfer) packetSize() int {
+	if t.sftp.MaxPacket > 0 {
+		return t.sftp.MaxPacket
+	}
+	return 32768
+}
+
+/*
+* Internal helper that copies a large file as ranges at the same time, each range is copied front to back
+* Inputs: in (transferReader) - source file, out (transferWriter) - destination file, offset (int64) - position to start at, size (int64) - source size, tracker (*transferTracker) - progress of the transfer
+* Outputs: int64 containing the bytes after offset that are written without gaps, error from the first range that fails
+ */
+func (t *treeTransfer) copyChunks(in transferReader, out transferWriter, offset, size int64, tracker *transferTracker) (int64, error) {
+	chunk := t.sftp.ChunkSize
+	count := int((size - offset + chunk - 1) / chunk)
+	written := make([]int64, count)
+	errs := make([]error, count)
+
+	starts := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < min(t.sftp.ParallelChunks, count); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range starts {
+				start := offset + int64(index)*chunk
+				written[index], errs[index] = copyRange(in, out, start, min(chunk, size-start), tracker)
+			}
+		}()
+	}
+	for index := 0; index < count; index++ {
+		starts <- index
+	}
+	close(starts)
+	wg.Wait()
+
+	// Only the ranges up to the first incomplete one are known to be written without gaps
+	var done int64
+	for index := 0; index < count; index++ {
+		done += written[index]
+		if errs[index] != nil {
+			return done, errs[index]
+		}
+	}
+	return done, nil
+}
+
+/*
+* Internal helper that copies a range of a file with large reads and writes at explicit offsets
+* Inputs: in (transferReader) - source file, out (transferWriter) - destination file, start (int64) - offset of the range, n (int64) - length of the range, tracker (*transferTracker) - progress of the transfer
+* Outputs: int64 containing the bytes written from the start of the range, error if reading or writing fails
+ */
+func copyRange(in transferReader, out transferWriter, start, n int64, tracker *transferTracker) (int64, error) {
+	buf := make([]byte, min(n, transferBufferSize))
+	var done int64
+	for done < n {
+		read, err := in.ReadAt(buf[:min(n-done, int64(len(buf)))], start+done)
+		if read > 0 {
+			if n, err := out.WriteAt(buf[:read], start+done); err != nil {
+				return done + int64(n), err
+			}
+			done += int64(read)
+			tracker.add(int64(read))
+		}
+		if err == io.EOF && done < n {
+			return done, fmt.Errorf("%w: file shrank during the transfer", io.ErrUnexpectedEOF)
+		}
+		if err != nil && err != io.EOF {
+			return done, err
+		}
+	}
+	return done, nil
+}
+
+/*
+* Internal helper that finds the file an interrupted copy continues and where
+* Resumed copies write a partial file front to back, so its size is where its data ends. Any other destination may
+* come from a copy that wrote ranges in parallel and was interrupted, leaving holes, so it is only kept when it hashes
+* the same as the start of the source. Atomic copies only skip complete destinations
+* Inputs: step (transferStep) - file step
+* Outputs: string containing the file to write, int64 containing the bytes already in it, the source size when the copy
+* is complete, error if hashing fails
+ */
+func (t *treeTransfer) resumeTarget(step transferStep) (string, int64, error) {
+	size := step.info.Size()
+	if !t.config.Atomic {
+		partial := partialName(step.dst)
+		if info, err := t.dst.Stat(partial); err == nil {
+			if !info.Mode().IsRegular() || info.Size() > size {
+				return partial, 0, nil
+			}
+			matches, err := t.prefixMatches(step, partial, info.Size(), t.config.VerifyResume)
+			if err != nil || !matches {
+				return partial, 0, err
+			}
+			return partial, info.Size(), nil
+		}
+	}
+
+	info, err := t.dst.Stat(step.dst)
+	if err == nil && info.Mode().IsRegular() && info.Size() > 0 && info.Size() <= size && (!t.config.Atomic || info.Size() == size) {
+		matches, err := t.prefixMatches(step, step.dst, info.Size(), true)
+		if err != nil {
+			return "", 0, err
+		}
+		if matches {
+			return step.dst, info.Size(), nil
+		}
+	}
+	if t.config.Atomic {
+		return atomicTempName(step.dst), 0, nil
+	}
+	return partialName(step.dst), 0, nil
+}
+
+/*
+* Internal helper that compares the SHA-256 of the first bytes of the source and a destination file
+* Both are hashed at the same time, a remote one by a command on the host when possible
+* Inputs: step (transferStep) - file step, name (string) - destination file, n (int64) - number of bytes to compare,
+* verify (bool) - whether to hash, false trusts the data
+* Outputs: bool - true if the data matches or is not verified, error if a file cannot be hashed
+ */
+func (t *treeTransfer) prefixMatches(step transferStep, name string, n int64, verify bool) (bool, error) {
+	if !verify || n == 0 {
+		return true, nil
+	}
+
+	var srcHash string
+	var srcErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srcHash, srcErr = t.src.ChecksumPrefix(step.src, n, ChecksumSHA256)
+	}()
+	dstHash, dstErr := t.dst.ChecksumPrefix(name, n, ChecksumSHA256)
+	<-done
+
+	if srcErr != nil {
+		return false, fmt.Errorf("failed to hash %s: %w", step.src, srcErr)
+	}
+	if dstErr != nil {
+		return false, fmt.Errorf("failed to hash %s: %w", name, dstErr)
+	}
+	return srcHash == dstHash, nil
+}
+
+/*
+* Internal helper that names the partial file a resumed copy writes, the destination is only replaced once it is complete
+* Inputs: name (string) - destination path
+* Outputs: string containing the destination path with the partial suffix
+ */
+func partialName(name string) string {
+	return name + ".dingo-partial"
+}
+
+/*
+* Internal helper that names the temporary file of an atomic write, in the same directory so the rename cannot cross filesystems
+* Inputs: name (string) - destination path
+* Outputs: string containing the destination path with a random suffix
+ */
+func atomicTempName(name string) string {
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	return name + ".dingo-" + hex.EncodeToString(suffix) + ".tmp"
+}
+
+/*
+* Internal helper that reads the source attributes a transfer preserves, before copying changes the access time
+* Inputs: src (string) - source path, info (os.FileInfo) - source information
+* Outputs: *fileAttributes, nil when not preserving, error if the attributes cannot be read
+ */
+func (t *treeTransfer) sourceAttributes(src string, info os.FileInfo) (*fileAttributes, error) {
+	if !t.config.Preserve && !t.config.PreserveOwner {
+		return nil, nil
+	}
+	attrs, err := t.src.Attributes(src, info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attributes of %s: %w", src, err)
+	}
+	return &attrs, nil
+}
+
+/*
+* Internal helper that applies the source permissions and modification time to a destination file or directory
+* When preserving, the access time is copied too instead of set to the modification time, and the owner when requested
+* Inputs: step (transferStep) - file or stepDirDone step with the source information
+* Outputs: error if the metadata cannot be set
+ */
+func (t *treeTransfer) applyMetadata(step transferStep) error {
+	info := step.info
+	atime := info.ModTime()
+	if step.attrs != nil {
+		atime = step.attrs.atime
+		// The owner goes first, changing it can clear the setuid and setgid bits
+		if t.config.PreserveOwner && step.attrs.uid >= 0 {
+			if err := t.dst.Chown(step.dst, step.attrs.uid, step.attrs.gid); err != nil {
+				return fmt.Errorf("failed to set owner of %s: %w", step.dst, err)
+			}
+		}
+	}
+	if err := t.dst.Chmod(step.dst, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set mode of %s: %w", step.dst, err)
+	}
+	if err := t.dst.Chtimes(step.dst, atime, info.ModTime()); err != nil {
+		return fmt.Errorf("failed to set times of %s: %w", step.dst, err)
+	}
+	return nil
+}
+
+/*
+* Internal helper that reports whether a path matches one of the exclude patterns
+* Inputs: rel (string) - slash-separated path relative to the transfer root
+* Outputs: bool reporting whether the path is excluded
+ */
+func (t *treeTransfer) excluded(rel string) bool {
+	return matchTransferPatterns(t.config.Exclude, rel)
+}
+
+/*
+* Internal helper that reports whether a file passes the include and exclude patterns
+* Inputs: rel (string) - slash-separated path relative to the transfer root
+* Outputs: bool reporting whether the file is transferred
+ */
+func (t *treeTransfer) included(rel string) bool {
+	if t.excluded(rel) {
+		return false
+	}
+	return len(t.config.Include) == 0 || matchTransferPatterns(t.config.Include, rel)
+}
+
+/*
+* Internal helper that matches a relative path against glob patterns
+* Patterns without a "/" are matched against the last path element, the others against the whole relative path
+* Inputs: patterns ([]string) - path.Match patterns, validated before the transfer, rel (string) - slash-separated relative path
+* Outputs: bool reporting whether any pattern matches
+ */
+func matchTransferPatterns(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,608 @@
+package dingo
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// transferTestTime is the modification time given to every file of the test tree
+var transferTestTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+/*
+* Test helper that creates a directory tree with files, modes, an excluded file and symbolic links
+* Inputs: t (*testing.T) - test context
+* Outputs: string containing the root of the tree
+ */
+func createTransferTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]os.FileMode{
+		"config.json":            0600,
+		"weights/model.bin":      0644,
+		"weights/run.sh":         0755,
+		"weights/logs/train.log": 0644,
+		"scratch.tmp":            0644,
+	}
+	for name, mode := range files {
+		file := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("MkdirAll failed: %v", err)
+		}
+		if err := os.WriteFile(file, []byte("content of "+filepath.Base(name)), mode); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		os.Chmod(file, mode)
+		os.Chtimes(file, transferTestTime, transferTestTime)
+	}
+	os.Symlink("config.json", filepath.Join(root, "current.json"))
+	os.Symlink("weights", filepath.Join(root, "latest"))
+	return root
+}
+
+/*
+* Test helper that checks a transferred file's contents, mode and modification time
+* Inputs: t (*testing.T) - test context, root (string) - destination root, name (string) - relative path, mode (os.FileMode) - expected permissions
+* Outputs: none
+ */
+func checkTransferredFile(t *testing.T, root, name string, mode os.FileMode) {
+	t.Helper()
+	file := filepath.Join(root, name)
+	info, err := os.Lstat(file)
+	if err != nil {
+		t.Errorf("Expected %s to be transferred: %v", name, err)
+		return
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm() != mode {
+		t.Errorf("%s: expected regular file with mode %v, got %v", name, mode, info.Mode())
+	}
+	if !info.ModTime().Equal(transferTestTime) {
+		t.Errorf("%s: expected mtime %v, got %v", name, transferTestTime, info.ModTime())
+	}
+	if data, _ := os.ReadFile(file); string(data) != "content of "+filepath.Base(name) {
+		t.Errorf("%s: unexpected contents %q", name, data)
+	}
+}
+
+func TestUploadDir_FollowSymlinks(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	src := createTransferTree(t)
+	dst := filepath.Join(t.TempDir(), "upload")
+
+	if err := fs.UploadDir(src, dst, WithExclude("*.tmp")); err != nil {
+		t.Fatalf("UploadDir failed: %v", err)
+	}
+
+	checkTransferredFile(t, dst, "config.json", 0600)
+	checkTransferredFile(t, dst, "weights/model.bin", 0644)
+	checkTransferredFile(t, dst, "weights/run.sh", 0755)
+	checkTransferredFile(t, dst, "weights/logs/train.log", 0644)
+	checkTransferredFile(t, dst, "latest/run.sh", 0755)
+	if data, err := os.ReadFile(filepath.Join(dst, "current.json")); err != nil || string(data) != "content of config.json" {
+		t.Errorf("Expected followed link to be copied, got %q, %v", data, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "scratch.tmp")); !os.IsNotExist(err) {
+		t.Errorf("Expected excluded file to be left out, got %v", err)
+	}
+}
+
+func TestDownloadDir_PreserveSymlinks(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	src := createTransferTree(t)
+	dst := filepath.Join(t.TempDir(), "download")
+
+	if err := fs.DownloadDir(src, dst, WithSymlinks(SymlinkPreserve), WithExclude("logs")); err != nil {
+		t.Fatalf("DownloadDir failed: %v", err)
+	}
+
+	checkTransferredFile(t, dst, "config.json", 0600)
+	checkTransferredFile(t, dst, "weights/run.sh", 0755)
+	checkTransferredFile(t, dst, "scratch.tmp", 0644)
+	for link, target := range map[string]string{"current.json": "config.json", "latest": "weights"} {
+		if got, err := os.Readlink(filepath.Join(dst, link)); err != nil || got != target {
+			t.Errorf("%s: expected link to %s, got %q, %v", link, target, got, err)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "weights", "logs")); !os.IsNotExist(err) {
+		t.Errorf("Expected excluded directory to be left out, got %v", err)
+	}
+
+	// A second transfer replaces the links it created
+	if err := fs.DownloadDir(src, dst, WithSymlinks(SymlinkPreserve)); err != nil {
+		t.Errorf("Repeated DownloadDir failed: %v", err)
+	}
+}
+
+func TestUploadDir_SkipSymlinksAndInclude(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	src := createTransferTree(t)
+	dst := t.TempDir()
+
+	if err := fs.UploadDir(src, dst, WithSymlinks(SymlinkSkip), WithInclude("*.log", "weights/*.sh")); err != nil {
+		t.Fatalf("UploadDir failed: %v", err)
+	}
+
+	checkTransferredFile(t, dst, "weights/logs/train.log", 0644)
+	checkTransferredFile(t, dst, "weights/run.sh", 0755)
+	for _, name := range []string{"config.json", "weights/model.bin", "current.json", "latest"} {
+		if _, err := os.Lstat(filepath.Join(dst, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be left out, got %v", name, err)
+		}
+	}
+}
+
+func TestUploadDir_Errors(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	src := createTransferTree(t)
+
+	os.Symlink("..", filepath.Join(src, "weights", "parent"))
+	if err := fs.UploadDir(src, t.TempDir()); !errors.Is(err, ErrSymlinkLoop) {
+		t.Errorf("Expected ErrSymlinkLoop, got %v", err)
+	}
+	if err := fs.UploadDir(filepath.Join(src, "config.json"), t.TempDir()); err == nil {
+		t.Error("Expected error uploading a file with UploadDir")
+	}
+	if err := fs.UploadDir(src, t.TempDir(), WithExclude("[")); err == nil {
+		t.Error("Expected error for an invalid pattern")
+	}
+	if err := fs.UploadDir(src, t.TempDir(), WithSymlinks("copy")); err == nil {
+		t.Error("Expected error for an unknown symlink policy")
+	}
+}
+
+func TestRemoteFileSystem_UploadDir_WithError(t *testing.T) {
+	rfs := createTestRemoteFileSystem(nil, true)
+
+	if err := rfs.UploadDir("/local/dir", "/remote/dir"); err == nil || err.Error() != "test SFTP error" {
+		t.Errorf("Expected 'test SFTP error', got: %v", err)
+	}
+	if err := rfs.DownloadDir("/remote/dir", "/local/dir"); err == nil || err.Error() != "test SFTP error" {
+		t.Errorf("Expected 'test SFTP error', got: %v", err)
+	}
+}
+
+func TestUpload_Progress(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	src := filepath.Join(t.TempDir(), "checkpoint.bin")
+	data := make([]byte, 1<<20)
+	os.WriteFile(src, data, 0644)
+
+	var reports []TransferProgress
+	err := fs.Upload(src, filepath.Join(t.TempDir(), "checkpoint.bin"),
+		WithProgress(func(p TransferProgress) { reports = append(reports, p) }),
+		WithProgressInterval(0))
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	if len(reports) < 2 {
+		t.Fatalf("Expected progress while copying and at the end, got %d reports", len(reports))
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i].BytesDone < reports[i-1].BytesDone {
+			t.Errorf("Progress went backwards: %d after %d", reports[i].BytesDone, reports[i-1].BytesDone)
+		}
+	}
+	last := reports[len(reports)-1]
+	if last.BytesDone != int64(len(data)) || last.BytesTotal != int64(len(data)) || last.FilesDone != 1 || last.FilesTotal != 1 {
+		t.Errorf("Unexpected final progress %+v", last)
+	}
+	if last.File != src || last.Rate <= 0 {
+		t.Errorf("Expected file %s and a rate, got %+v", src, last)
+	}
+}
+
+func TestDownloadDir_Progress(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	src := createTransferTree(t)
+
+	var last TransferProgress
+	calls := 0
+	err := fs.DownloadDir(src, t.TempDir(), WithSymlinks(SymlinkSkip), WithProgress(func(p TransferProgress) {
+		last = p
+		calls++
+	}))
+	if err != nil {
+		t.Fatalf("DownloadDir failed: %v", err)
+	}
+
+	// Five files named "content of <name>"
+	var size int64
+	for _, name := range []string{"config.json", "model.bin", "run.sh", "train.log", "scratch.tmp"} {
+		size += int64(len("content of " + name))
+	}
+	if calls == 0 || last.FilesDone != 5 || last.FilesTotal != 5 || last.BytesDone != size || last.BytesTotal != size {
+		t.Errorf("Unexpected final progress %+v after %d calls", last, calls)
+	}
+}
+
+/*
+* Test helper that writes a file of pseudo-random data
+* Inputs: t (*testing.T) - test context, name (string) - file path, size (int) - file size
+* Outputs: []byte containing the written data
+ */
+func writeRandomFile(t *testing.T, name string, size int) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	rand.Read(data)
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return data
+}
+
+func TestUpload_Resume(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "weights.bin"), filepath.Join(dir, "partial.bin")
+	data := writeRandomFile(t, src, 1<<20)
+
+	// The partial file of a resumed copy has a corrupted byte that is only noticed with verification
+	partial := append([]byte(nil), data[:300<<10]...)
+	partial[10] ^= 0xff
+	os.WriteFile(partialName(dst), partial, 0644)
+
+	var last TransferProgress
+	progress := WithProgress(func(p TransferProgress) { last = p })
+	if err := fs.Upload(src, dst, WithResume(true), progress); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	got, _ := os.ReadFile(dst)
+	if len(got) != len(data) || got[10] == data[10] || !bytes.Equal(got[11:], data[11:]) {
+		t.Error("Expected the transfer to continue after the partial data")
+	}
+	if last.BytesDone != int64(len(data)) {
+		t.Errorf("Expected resumed bytes to count as done, got %+v", last)
+	}
+	if _, err := os.Stat(partialName(dst)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the partial file to be renamed, got %v", err)
+	}
+
+	os.Remove(dst)
+	os.WriteFile(partialName(dst), partial, 0644)
+	if err := fs.Upload(src, dst, WithResume(true), WithVerifyResume(true)); err != nil {
+		t.Fatalf("Verified upload failed: %v", err)
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, data) {
+		t.Error("Expected a differing prefix to be copied again")
+	}
+
+	// A destination that is not a partial file is always checked, it may come from an interrupted parallel copy
+	os.WriteFile(dst, partial, 0644)
+	if err := fs.Upload(src, dst, WithResume(true)); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, data) {
+		t.Error("Expected an unverified destination to be copied again")
+	}
+}
+
+func TestDownload_Resume(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "remote.bin"), filepath.Join(dir, "local.bin")
+	data := writeRandomFile(t, src, 512<<10)
+
+	tests := map[string][]byte{
+		"partial":  data[:100<<10],
+		"complete": data,
+		"empty":    {},
+		"larger":   append(append([]byte(nil), data...), "trailing"...),
+		"no file":  nil,
+		"verified": data[:200<<10],
+	}
+	for name, existing := range tests {
+		os.Remove(dst)
+		if existing != nil {
+			os.WriteFile(dst, existing, 0644)
+		}
+		if err := fs.Download(src, dst, WithResume(true), WithVerifyResume(name == "verified")); err != nil {
+			t.Fatalf("%s: Download failed: %v", name, err)
+		}
+		if got, _ := os.ReadFile(dst); !bytes.Equal(got, data) {
+			t.Errorf("%s: downloaded file differs from the source", name)
+		}
+	}
+}
+
+func TestUploadDir_Resume(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	src := createTransferTree(t)
+	dst := t.TempDir()
+
+	// An interrupted earlier run left a partial file behind
+	os.MkdirAll(filepath.Join(dst, "weights"), 0755)
+	os.WriteFile(filepath.Join(dst, "weights", "model.bin"), []byte("content"), 0644)
+
+	if err := fs.UploadDir(src, dst, WithResume(true), WithVerifyResume(true)); err != nil {
+		t.Fatalf("UploadDir failed: %v", err)
+	}
+	checkTransferredFile(t, dst, "weights/model.bin", 0644)
+	checkTransferredFile(t, dst, "config.json", 0600)
+}
+
+// droppedLinkFS is a local transferFS whose writes to a range fail and whose truncate fails, like a dropped SFTP connection
+type droppedLinkFS struct {
+	localTransferFS
+	failFrom, failTo int64
+}
+
+func (fs droppedLinkFS) Create(name string, perm os.FileMode) (transferWriter, error) {
+	f, err := fs.localTransferFS.Create(name, perm)
+	return droppedLinkWriter{f, fs}, err
+}
+
+func (fs droppedLinkFS) Truncate(name string, size int64) error {
+	return errors.New("connection lost")
+}
+
+type droppedLinkWriter struct {
+	transferWriter
+	fs droppedLinkFS
+}
+
+func (w droppedLinkWriter) WriteAt(p []byte, off int64) (int, error) {
+	if off < w.fs.failTo && off+int64(len(p)) > w.fs.failFrom {
+		return 0, errors.New("connection lost")
+	}
+	return w.transferWriter.WriteAt(p, off)
+}
+
+func TestTransfer_ResumeInterruptedChunks(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "model.bin"), filepath.Join(dir, "copy.bin")
+	data := writeRandomFile(t, src, 1<<20)
+	info, _ := os.Stat(src)
+	step := transferStep{kind: stepFile, src: src, dst: dst, info: info}
+
+	// The second of four parallel ranges fails, the others are written and the failed truncate leaves a hole
+	sftp := &SftpConfig{ParallelChunks: 4, ChunkSize: 256 << 10}
+	interrupted := &treeTransfer{src: localTransferFS{}, dst: droppedLinkFS{failFrom: 256 << 10, failTo: 512 << 10}, config: &TransferConfig{}, sftp: sftp}
+	if err := interrupted.copyFile(step, newTransferTracker(interrupted.config, 1, info.Size())); err == nil {
+		t.Fatal("Expected the interrupted copy to fail")
+	}
+	if got, _ := os.ReadFile(dst); len(got) <= 512<<10 || bytes.Equal(got, data[:len(got)]) {
+		t.Fatalf("Expected the interrupted copy to leave a hole, got %d bytes", len(got))
+	}
+
+	config := &TransferConfig{Resume: true}
+	resumed := &treeTransfer{src: localTransferFS{}, dst: localTransferFS{}, config: config, sftp: sftp}
+	if err := resumed.copyFile(step, newTransferTracker(config, 1, info.Size())); err != nil {
+		t.Fatalf("Resumed copy failed: %v", err)
+	}
+	if got, _ := os.ReadFile(dst); !bytes.Equal(got, data) {
+		t.Error("Expected the resumed copy to match the source despite the hole")
+	}
+}
+
+func TestTransfer_ParallelChunks(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+	fs := client.FileSystem(append(FastSftpOptions(), WithChunkSize(64<<10), WithWorkers(3))...)
+	dir := t.TempDir()
+	data := writeRandomFile(t, filepath.Join(dir, "model.bin"), 1<<20+123)
+
+	var last TransferProgress
+	progress := WithProgress(func(p TransferProgress) { last = p })
+	if err := fs.Upload(filepath.Join(dir, "model.bin"), filepath.Join(dir, "uploaded.bin"), progress); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "uploaded.bin")); !bytes.Equal(got, data) {
+		t.Error("Uploaded file differs from the source")
+	}
+	if last.BytesDone != int64(len(data)) {
+		t.Errorf("Expected all chunks to count as progress, got %+v", last)
+	}
+
+	if err := fs.Download(filepath.Join(dir, "uploaded.bin"), filepath.Join(dir, "downloaded.bin")); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "downloaded.bin")); !bytes.Equal(got, data) {
+		t.Error("Downloaded file differs from the source")
+	}
+
+	// Several files at once through the worker pool
+	os.Mkdir(filepath.Join(dir, "tree"), 0755)
+	for i := 0; i < 5; i++ {
+		writeRandomFile(t, filepath.Join(dir, "tree", fmt.Sprintf("shard-%d.bin", i)), 200<<10)
+	}
+	if err := fs.UploadDir(filepath.Join(dir, "tree"), filepath.Join(dir, "copy")); err != nil {
+		t.Fatalf("UploadDir failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("shard-%d.bin", i)
+		want, _ := os.ReadFile(filepath.Join(dir, "tree", name))
+		if got, _ := os.ReadFile(filepath.Join(dir, "copy", name)); !bytes.Equal(got, want) {
+			t.Errorf("%s differs from the source", name)
+		}
+	}
+}
+
+func TestClient_FileSystem_SessionOptions(t *testing.T) {
+	client := newClient(createExecSSHServer(t), nil)
+
+	shared := client.FileSystem().(*remoteFileSystem)
+	again := client.FileSystem(WithWorkers(8)).(*remoteFileSystem)
+	separate := client.FileSystem(WithMaxPacket(1024)).(*remoteFileSystem)
+	if shared.err != nil || separate.err != nil {
+		t.Fatalf("FileSystem failed: %v, %v", shared.err, separate.err)
+	}
+	if again.sftp != shared.sftp {
+		t.Error("Expected transfer options to share the SFTP session")
+	}
+	if separate.sftp == shared.sftp {
+		t.Error("Expected a different packet size to open a separate SFTP session")
+	}
+
+	// Small packets still copy correctly
+	dir := t.TempDir()
+	data := writeRandomFile(t, filepath.Join(dir, "src"), 100<<10)
+	if err := separate.Upload(filepath.Join(dir, "src"), filepath.Join(dir, "dst")); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "dst")); !bytes.Equal(got, data) {
+		t.Error("Uploaded file differs from the source")
+	}
+	separate.Close()
+}
+
+// failingRangeWriter is a transferWriter whose writes fail from an offset on
+type failingRangeWriter struct {
+	failAt int64
+	mu     sync.Mutex
+	data   map[int64]int
+}
+
+func (w *failingRangeWriter) Write(p []byte) (int, error) { return 0, errors.New("not supported") }
+func (w *failingRangeWriter) Close() error                { return nil }
+func (w *failingRangeWriter) Sync() error                 { return nil }
+
+func (w *failingRangeWriter) WriteAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) > w.failAt {
+		return 0, errors.New("connection lost")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.data[off] = len(p)
+	return len(p), nil
+}
+
+// memoryReader is a transferReader over a byte slice
+type memoryReader struct {
+	*bytes.Reader
+}
+
+func (memoryReader) Close() error { return nil }
+
+func TestCopyChunks_ContiguousPrefixOnFailure(t *testing.T) {
+	transfer := &treeTransfer{sftp: &SftpConfig{ParallelChunks: 4, ChunkSize: 100}}
+	in := memoryReader{bytes.NewReader(make([]byte, 1000))}
+	out := &failingRangeWriter{failAt: 450, data: make(map[int64]int)}
+
+	written, err := transfer.copyChunks(in, out, 0, 1000, newTransferTracker(&TransferConfig{}, 1, 1000))
+	if err == nil {
+		t.Fatal("Expected the failing range to be reported")
+	}
+	// Ranges 0-399 are complete, the range from 400 fails, later ranges may have been written but leave a gap
+	if written != 400 {
+		t.Errorf("Expected 400 bytes written without gaps, got %d", written)
+	}
+}
+
+func TestOrderedWriter_SinglePackets(t *testing.T) {
+	in := memoryReader{bytes.NewReader(make([]byte, 1000))}
+	out := &failingRangeWriter{failAt: 450, data: make(map[int64]int)}
+
+	written, err := copyRange(in, orderedWriter{out, 100}, 0, 1000, newTransferTracker(&TransferConfig{}, 1, 1000))
+	if err == nil {
+		t.Fatal("Expected the failing write to be reported")
+	}
+	// Every packet before the failure is written, nothing after it
+	if written != 400 || len(out.data) != 4 {
+		t.Errorf("Expected 400 bytes in 4 packets, got %d bytes in %v", written, out.data)
+	}
+	for off, n := range out.data {
+		if n != 100 || off%100 != 0 || off >= 400 {
+			t.Errorf("Unexpected write of %d bytes at %d", n, off)
+		}
+	}
+}
+
+func TestUpload_Atomic(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	local := filepath.Join(t.TempDir(), "model.bin")
+	data := writeRandomFile(t, local, 3<<20)
+	dir := t.TempDir()
+	remote := filepath.Join(dir, "model.bin")
+	writeRandomFile(t, remote, 1<<20)
+
+	// A partial destination is an earlier complete version, resume must not continue it
+	if err := fs.Upload(local, remote, WithAtomic(true), WithResume(true), WithVerify(true)); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if got, _ := os.ReadFile(remote); !bytes.Equal(got, data) {
+		t.Error("Uploaded data differs from the source")
+	}
+
+	// Replacing a non-empty directory fails after the copy, the temporary file is removed
+	busy := filepath.Join(dir, "busy")
+	os.MkdirAll(filepath.Join(busy, "child"), 0755)
+	if err := fs.Upload(local, busy, WithAtomic(true)); err == nil {
+		t.Error("Expected error replacing a non-empty directory")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("Expected only model.bin and busy in %s, got %d entries", dir, len(entries))
+	}
+
+	back := filepath.Join(t.TempDir(), "download")
+	if err := fs.DownloadDir(createTransferTree(t), back, WithAtomic(true), WithSymlinks(SymlinkSkip)); err != nil {
+		t.Fatalf("DownloadDir failed: %v", err)
+	}
+	checkTransferredFile(t, back, "weights/run.sh", 0755)
+}
+
+func TestUpload_PreserveMetadata(t *testing.T) {
+	fs := newClient(createExecSSHServer(t), nil).FileSystem()
+	local := filepath.Join(t.TempDir(), "run.sh")
+	os.WriteFile(local, []byte("#!/bin/sh"), 0750)
+	os.Chmod(local, 0750)
+	atime := time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+	os.Chtimes(local, atime, transferTestTime)
+	dir := t.TempDir()
+
+	// Without preserving, new files still get the source permissions but a fresh modification time
+	plain := filepath.Join(dir, "plain.sh")
+	if err := fs.Upload(local, plain); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if info, _ := os.Stat(plain); info.Mode().Perm() != 0750 || info.ModTime().Equal(transferTestTime) {
+		t.Errorf("Expected mode 0750 and a new mtime, got %v %v", info.Mode(), info.ModTime())
+	}
+
+	checkPreserved := func(name string, atime time.Time) {
+		t.Helper()
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if info.Mode().Perm() != 0750 || !info.ModTime().Equal(transferTestTime) {
+			t.Errorf("%s: expected mode 0750 and mtime %v, got %v %v", name, transferTestTime, info.Mode(), info.ModTime())
+		}
+		attrs, err := localFileAttributes(name, info)
+		if err != nil {
+			t.Fatalf("localFileAttributes failed: %v", err)
+		}
+		if !attrs.atime.Equal(atime) {
+			t.Errorf("%s: expected atime %v, got %v", name, atime, attrs.atime)
+		}
+		if attrs.uid != os.Getuid() || attrs.gid != os.Getgid() {
+			t.Errorf("%s: expected owner %d:%d, got %d:%d", name, os.Getuid(), os.Getgid(), attrs.uid, attrs.gid)
+		}
+	}
+
+	// Reading the source for the first upload moved its access time
+	os.Chtimes(local, atime, transferTestTime)
+	preserved := filepath.Join(dir, "preserved.sh")
+	if err := fs.Upload(local, preserved, WithPreserveOwner(true)); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	checkPreserved(preserved, atime)
+
+	// The test server reports the modification time as access time
+	back := filepath.Join(t.TempDir(), "run.sh")
+	if err := fs.Download(preserved, back, WithPreserve(true)); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	checkPreserved(back, transferTestTime)
+
+	tree := filepath.Join(t.TempDir(), "tree")
+	if err := fs.UploadDir(createTransferTree(t), tree, WithPreserve(true), WithSymlinks(SymlinkSkip)); err != nil {
+		t.Fatalf("UploadDir failed: %v", err)
+	}
+	checkTransferredFile(t, tree, "config.json", 0600)
+}
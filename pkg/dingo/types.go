@@ -1,24 +1,50 @@
 package dingo
 
 import (
+	"context"
 	"io"
+	"io/fs"
+	"net"
+	"net/http"
 	"os"
 	"time"
 
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 // SSHClient represents the main interface for SSH operations
 type SSHClient interface {
 	// Command execution
 	Command(cmd string) CommandExecutor
-	Script(script string) CommandExecutor
-	ScriptFile(path string) CommandExecutor
+	Script(script string, opts ...ScriptOption) CommandExecutor
+	ScriptFile(path string, opts ...ScriptOption) CommandExecutor
+	ScriptTemplate(text string, vars map[string]any, opts ...ScriptOption) CommandExecutor
+	ScriptTemplateFS(fsys fs.FS, name string, vars map[string]any, opts ...ScriptOption) CommandExecutor
 
 	// Shell operations
 	Shell() Shell
 	InteractiveShell(config *TerminalConfig) Shell
 
+	// Persistent sessions
+	PersistentSession(name string, opts ...SessionOption) PersistentSession
+	ListSessions(opts ...SessionOption) ([]SessionInfo, error)
+
+	// Detached jobs
+	StartJob(command string, opts ...JobOption) (Job, error)
+	Job(id string, opts ...JobOption) Job
+	ListJobs(opts ...JobOption) ([]string, error)
+
+	// Port forwarding
+	ForwardLocal(localAddr, remoteAddr string, opts ...ForwardOption) (Forward, error)
+	ForwardRemote(remoteAddr, localAddr string, opts ...ForwardOption) (Forward, error)
+	ForwardDynamic(localAddr string, opts ...ForwardOption) (Forward, error)
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+	HTTPTransport() *http.Transport
+
+	// Agent forwarding, off unless enabled
+	EnableAgentForwarding(keyring agent.Agent) error
+
 	// File operations
 	FileSystem(opts ...SftpOption) FileSystem
 
@@ -34,21 +60,75 @@ type CommandExecutor interface {
 	SmartOutput() ([]byte, error)
 	SetStdio(stdout, stderr io.Writer) CommandExecutor
 	Cmd(cmd string) CommandExecutor
+	Sudo(opts ...SudoOption) CommandExecutor
+	WithPTY(config *TerminalConfig) CommandExecutor
+	ForwardSignals(signals <-chan os.Signal, grace time.Duration) CommandExecutor
 }
 
 // Shell represents an interface for interactive shell sessions
 type Shell interface {
 	Start(command string) error
+	ShellExec(commands ...string) error
+	Resize(width, height int) error
 	SetStdio(stdin io.Reader, stdout, stderr io.Writer) Shell
 }
 
+// PersistentSession represents a named terminal multiplexer session that survives the SSH connection
+type PersistentSession interface {
+	Name() string
+	Multiplexer() (Multiplexer, error)
+	Create(command string) error
+	Exists() (bool, error)
+	Attach(config *TerminalConfig) Shell
+	Detach() error
+	Kill() error
+	SendKeys(keys string) error
+	Capture() (string, error)
+}
+
+// Job represents a command running detached on the remote host, independent of the SSH connection
+type Job interface {
+	ID() string
+	Status() (*JobStatus, error)
+	Logs(stream JobStream, lines int) ([]byte, error)
+	Wait(timeout time.Duration) (*JobStatus, error)
+	Cancel() error
+	Remove() error
+}
+
+// Expecter represents an interface for scripted interaction with a running shell
+type Expecter interface {
+	Send(input string) error
+	SendLine(line string) error
+	Expect(patterns ...ExpectPattern) (*ExpectMatch, error)
+	ExpectTimeout(timeout time.Duration, patterns ...ExpectPattern) (*ExpectMatch, error)
+	Close() error
+}
+
+// SharedShell represents a shell whose terminal is mirrored to observers connecting on a local listener
+type SharedShell interface {
+	Shell
+	Observers() int
+}
+
+// Forward represents an open port forward proxying connections accepted on a listener
+type Forward interface {
+	Addr() net.Addr
+	Connections() int
+	Err() error
+	Close() error
+}
+
 // FileSystem represents an interface for remote file operations
 type FileSystem interface {
 	// File operations
 	ReadFile(name string) ([]byte, error)
 	WriteFile(name string, data []byte, perm os.FileMode) error
-	Upload(localPath, remotePath string) error
-	Download(remotePath, localPath string) error
+	WriteFileAtomic(name string, data []byte, perm os.FileMode) error
+	Upload(localPath, remotePath string, opts ...TransferOption) error
+	Download(remotePath, localPath string, opts ...TransferOption) error
+	UploadDir(localDir, remoteDir string, opts ...TransferOption) error
+	DownloadDir(remoteDir, localDir string, opts ...TransferOption) error
 
 	// Directory operations
 	Mkdir(path string) error
@@ -60,11 +140,19 @@ type FileSystem interface {
 	Stat(path string) (os.FileInfo, error)
 	Lstat(path string) (os.FileInfo, error)
 	ReadDir(path string) ([]os.FileInfo, error)
+	Checksum(path string, algo ChecksumAlgorithm) (string, error)
 
 	// File manipulation
 	Chmod(path string, mode os.FileMode) error
 	Chown(path string, uid, gid int) error
+	Chtimes(path string, atime, mtime time.Time) error
 	Rename(oldname, newname string) error
+	Truncate(path string, size int64) error
+
+	// Links
+	Symlink(oldname, newname string) error
+	ReadLink(path string) (string, error)
+	Link(oldname, newname string) error
 
 	// Cleanup
 	Close() error
@@ -114,13 +202,208 @@ const (
 	NonInteractiveShell
 )
 
+// ScriptOption represents a configuration option for script execution
+type ScriptOption func(*ScriptConfig)
+
+// ScriptConfig represents configuration for script execution
+type ScriptConfig struct {
+	Interpreter   string   // Explicit interpreter, e.g. "python3" or "/bin/bash -e"
+	UseShebang    bool     // Run the script under the interpreter named in its #! line
+	Args          []string // Positional arguments available to the script as $1, $2, ...
+	UploadExecute bool     // Upload the script to a remote file, execute it, then remove it
+	RemoteDir     string   // Remote directory used for uploaded scripts
+}
+
+// EscalationMethod represents the tool used to run commands with elevated privileges
+type EscalationMethod string
+
+const (
+	EscalateSudo EscalationMethod = "sudo"
+	EscalateSu   EscalationMethod = "su"
+	EscalateDoas EscalationMethod = "doas"
+)
+
+// CredentialProvider supplies the password requested during privilege escalation
+type CredentialProvider interface {
+	Password() (string, error)
+}
+
+// SudoOption represents a configuration option for privilege escalation
+type SudoOption func(*SudoConfig)
+
+// SudoConfig represents configuration for privilege escalation
+type SudoConfig struct {
+	Method      EscalationMethod
+	User        string
+	Credentials CredentialProvider
+}
+
+// Multiplexer represents the terminal multiplexer backing persistent sessions
+type Multiplexer string
+
+const (
+	MultiplexerAuto   Multiplexer = "" // Use tmux if installed, otherwise screen
+	MultiplexerTmux   Multiplexer = "tmux"
+	MultiplexerScreen Multiplexer = "screen"
+)
+
+// SessionInfo describes a persistent session found on the remote host
+type SessionInfo struct {
+	Name        string
+	Multiplexer Multiplexer
+	Attached    bool
+}
+
+// RecordOption represents a configuration option for session recording
+type RecordOption func(*RecordConfig)
+
+// RecordConfig represents configuration for asciicast session recording
+type RecordConfig struct {
+	Width       int
+	Height      int
+	Term        string
+	Title       string
+	RecordInput bool
+}
+
+// SessionOption represents a configuration option for persistent sessions
+type SessionOption func(*SessionConfig)
+
+// SessionConfig represents configuration for persistent sessions
+type SessionConfig struct {
+	Multiplexer Multiplexer
+}
+
+// ShareOption represents a configuration option for shell sharing
+type ShareOption func(*ShareConfig)
+
+// ShareConfig represents configuration for shell sharing
+type ShareConfig struct {
+	AllowInput  bool // Observers may type into the shell, otherwise their input is discarded
+	QueueLength int  // Output chunks buffered per observer before a slow observer is disconnected
+}
+
+// ForwardOption represents a configuration option for port forwarding
+type ForwardOption func(*ForwardConfig)
+
+// ForwardConfig represents configuration for port forwarding
+type ForwardConfig struct {
+	ErrorHandler func(err error) // Called for connections that fail to be forwarded, nil to ignore them
+}
+
+// JobState represents the lifecycle state of a detached job
+type JobState string
+
+const (
+	JobRunning   JobState = "running"
+	JobFinished  JobState = "finished"  // The command exited, see JobStatus.ExitCode
+	JobCancelled JobState = "cancelled" // Cancel terminated the command
+	JobLost      JobState = "lost"      // The process is gone without recording an exit code, e.g. after a reboot
+)
+
+// JobStream selects the output stream of a detached job
+type JobStream string
+
+const (
+	JobStdout JobStream = "stdout"
+	JobStderr JobStream = "stderr"
+)
+
+// JobStatus describes the state of a detached job
+type JobStatus struct {
+	ID         string
+	State      JobState
+	PID        int
+	ExitCode   int // Valid when State is JobFinished
+	StartedAt  time.Time
+	FinishedAt time.Time // Zero until the command has exited
+}
+
+// JobOption represents a configuration option for detached jobs
+type JobOption func(*JobConfig)
+
+// JobConfig represents configuration for detached jobs
+type JobConfig struct {
+	Dir          string        // Remote directory holding one subdirectory per job, relative paths start at the login directory
+	WorkDir      string        // Remote working directory of the command, "" for the login directory
+	PollInterval time.Duration // Interval between status checks in Wait
+}
+
+// ExpectOption represents a configuration option for scripted shell interaction
+type ExpectOption func(*ExpectConfig)
+
+// ExpectConfig represents configuration for scripted shell interaction
+type ExpectConfig struct {
+	Timeout   time.Duration // Default time Expect waits for a match
+	Log       io.Writer     // Receives a copy of all shell output, nil to disable
+	MaxBuffer int           // Unmatched output kept for matching, older output is discarded
+}
+
+// SymlinkPolicy selects how directory transfers handle symbolic links
+type SymlinkPolicy string
+
+const (
+	SymlinkFollow   SymlinkPolicy = "follow"   // Transfer the file or directory the link points to
+	SymlinkPreserve SymlinkPolicy = "preserve" // Recreate the link with the same target
+	SymlinkSkip     SymlinkPolicy = "skip"     // Leave links out
+)
+
+// ChecksumAlgorithm selects the hash used to verify transfers and by FileSystem.Checksum
+type ChecksumAlgorithm string
+
+const (
+	ChecksumSHA256 ChecksumAlgorithm = "sha256"
+	ChecksumSHA512 ChecksumAlgorithm = "sha512"
+	ChecksumSHA1   ChecksumAlgorithm = "sha1"
+	ChecksumMD5    ChecksumAlgorithm = "md5"
+)
+
+// TransferOption represents a configuration option for file transfers
+type TransferOption func(*TransferConfig)
+
+// TransferConfig represents configuration for file transfers
+type TransferConfig struct {
+	Symlinks SymlinkPolicy // How directory transfers handle symbolic links
+	Include  []string      // Glob patterns files must match to be transferred, all files when empty
+	Exclude  []string      // Glob patterns of files and directories that are not transferred
+
+	Resume       bool // Continue partial files of interrupted copies, destinations matching the source are kept, files are copied front to back
+	VerifyResume bool // With Resume, also compare SHA-256 hashes of partial files before continuing
+
+	Preserve      bool // Copy the mode, access and modification times of single files too, and the access times of directory transfers
+	PreserveOwner bool // Copy the source uid and gid as well, implies Preserve and usually needs root at the destination
+
+	Atomic bool // Write each file to a temporary file in the same directory and rename it over the destination once complete
+
+	Verify   bool              // Compare checksums of every source and destination file once it is copied
+	Checksum ChecksumAlgorithm // Hash used by Verify
+
+	Progress         func(progress TransferProgress) // Called while data is copied and once when the transfer completes, nil to disable
+	ProgressInterval time.Duration                   // Minimum time between progress calls
+}
+
+// TransferProgress describes how far a file or directory transfer has got
+type TransferProgress struct {
+	File       string        // Source path of the file being copied
+	FilesDone  int           // Files copied completely
+	FilesTotal int           // Files to copy
+	BytesDone  int64         // Bytes copied, including the data resumed copies found at the destination
+	BytesTotal int64         // Total size of the files to copy
+	Rate       float64       // Average throughput in bytes per second, bytes skipped by resumed copies are not counted
+	Elapsed    time.Duration // Time since the transfer started
+}
+
 // SftpOption represents a configuration option for SFTP operations
 type SftpOption func(*SftpConfig)
 
 // SftpConfig represents configuration for SFTP operations
 type SftpConfig struct {
-	MaxPacket int
-	UseFstat  bool
+	MaxPacket          int   // Largest data payload per SFTP packet, sizes above 32768 are not supported by every server
+	UseFstat           bool  // Use fstat on open files instead of stat when downloading, for servers that limit open files
+	ConcurrentRequests int   // SFTP requests in flight per file operation, 0 for the sftp package default of 64
+	ParallelChunks     int   // Ranges of a large file copied at the same time, 1 copies files sequentially
+	ChunkSize          int64 // Size of the ranges, files smaller than two chunks are copied sequentially
+	Workers            int   // Files of a directory transfer copied at the same time
 }
 
 // Default configurations
@@ -140,8 +423,53 @@ var (
 		Modes:  ssh.TerminalModes{},
 	}
 
+	DefaultScriptConfig = &ScriptConfig{
+		RemoteDir: "/tmp",
+	}
+
+	DefaultSudoConfig = &SudoConfig{
+		Method: EscalateSudo,
+		User:   "root",
+	}
+
+	DefaultRecordConfig = &RecordConfig{
+		Width:  DefaultTerminalConfig.Width,
+		Height: DefaultTerminalConfig.Height,
+		Term:   DefaultTerminalConfig.Term,
+	}
+
+	DefaultSessionConfig = &SessionConfig{
+		Multiplexer: MultiplexerAuto,
+	}
+
+	DefaultShareConfig = &ShareConfig{
+		QueueLength: 1024,
+	}
+
+	DefaultForwardConfig = &ForwardConfig{}
+
+	DefaultJobConfig = &JobConfig{
+		Dir:          ".dingo/jobs",
+		PollInterval: 2 * time.Second,
+	}
+
+	DefaultExpectConfig = &ExpectConfig{
+		Timeout:   30 * time.Second,
+		MaxBuffer: 1 << 20,
+	}
+
+	DefaultTransferConfig = &TransferConfig{
+		Symlinks:         SymlinkFollow,
+		Checksum:         ChecksumSHA256,
+		ProgressInterval: 500 * time.Millisecond,
+	}
+
 	DefaultSftpConfig = &SftpConfig{
-		MaxPacket: 32768,
-		UseFstat:  true,
+		MaxPacket:          32768,
+		UseFstat:           true,
+		ConcurrentRequests: 64,
+		ParallelChunks:     4,
+		ChunkSize:          64 << 20,
+		Workers:            4,
 	}
 )